@@ -14,27 +14,23 @@ import (
 
 func TestGreetings(t *testing.T) {
 	tests := []struct {
-		_name     string
-		greetings []db.CreateGreetingParams
-		out       int
+		_name string
+		seeds []string
+		out   int
 	}{
 		{
 			_name: "empty",
 			out:   0,
 		},
 		{
-			_name:     "one greeting",
-			greetings: []db.CreateGreetingParams{{Emoji: "👋", Message: "hi", Name: "alice"}},
-			out:       1,
+			_name: "one greeting",
+			seeds: []string{"testdata/alice.sql"},
+			out:   1,
 		},
 		{
 			_name: "three greetings",
-			greetings: []db.CreateGreetingParams{
-				{Emoji: "👋", Message: "hi", Name: "alice"},
-				{Emoji: "🎉", Message: "hey", Name: "bob"},
-				{Emoji: "🌍", Message: "hello", Name: "charlie"},
-			},
-			out: 3,
+			seeds: []string{"testdata/*.sql"},
+			out:   3,
 		},
 	}
 
@@ -45,22 +41,30 @@ func TestGreetings(t *testing.T) {
 			a := assert.New(t)
 			r := require.New(t)
 
-			dbURL := cheetah.TestDB(t)
+			dbURL := cheetah.TestDB(t, cheetah.WithSeed(tt.seeds...), cheetah.WithKeepOnFailure())
 			conn, err := sql.Open("postgres", dbURL)
 			r.NoError(err)
 			defer conn.Close()
 
-			q := db.New(conn)
-			ctx := context.Background()
-
-			for _, g := range tt.greetings {
-				_, err := q.CreateGreeting(ctx, g)
-				r.NoError(err)
-			}
-
-			greetings, err := q.ListGreetings(ctx)
+			greetings, err := db.New(conn).ListGreetings(context.Background())
 			a.NoError(err)
 			a.Equal(tt.out, len(greetings))
 		})
 	}
 }
+
+func TestCreateGreeting(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	q := db.New(cheetah.TestTx(t, cheetah.WithSeed("testdata/friends.sql")))
+	ctx := context.Background()
+
+	g, err := q.CreateGreeting(ctx, db.CreateGreetingParams{Emoji: "👋", Message: "hi", Name: "alice"})
+	r.NoError(err)
+	a.Equal("alice", g.Name)
+
+	greetings, err := q.ListGreetings(ctx)
+	a.NoError(err)
+	a.Equal(3, len(greetings))
+}
@@ -0,0 +1 @@
+INSERT INTO greetings (emoji, message, name) VALUES ('👋', 'hi', 'alice');
@@ -0,0 +1,3 @@
+INSERT INTO greetings (emoji, message, name) VALUES
+    ('🎉', 'hey', 'bob'),
+    ('🌍', 'hello', 'charlie');
@@ -18,7 +18,11 @@ import (
 	"github.com/lmittmann/tint"
 
 	"github.com/housecat-inc/cheetah/pkg/api"
+	"github.com/housecat-inc/cheetah/pkg/artifact"
+	"github.com/housecat-inc/cheetah/pkg/code"
 	"github.com/housecat-inc/cheetah/pkg/config"
+	"github.com/housecat-inc/cheetah/pkg/gotest"
+	"github.com/housecat-inc/cheetah/pkg/mcp"
 	"github.com/housecat-inc/cheetah/pkg/pg"
 	"github.com/housecat-inc/cheetah/pkg/version"
 )
@@ -36,8 +40,13 @@ Usage:
   cheetah [flags] [command]
 
 Commands:
+  gc        Remove build artifacts no running space keeps
+  mcp       Serve the cheetah MCP tools over stdio
+  rollback  Relaunch a kept build: rollback [space] [build-id]
   status    Show cheetah and postgres status
   stop      Stop the running cheetah daemon
+  test      Run go test in the current space: test [go test args]
+            List tests that passed and failed at one commit: test --flaky
   update    Update cheetah to the latest version
   version   Print version
 
@@ -56,12 +65,24 @@ func main() {
 		case "-v", "--version", "version":
 			fmt.Println(version.Get())
 			return
+		case "gc":
+			gc()
+			return
+		case "mcp":
+			serveMCP()
+			return
+		case "rollback":
+			rollback(os.Args[2:])
+			return
 		case "status":
 			status()
 			return
 		case "stop":
 			stop()
 			return
+		case "test":
+			test(os.Args[2:])
+			return
 		case "update":
 			update()
 			return
@@ -77,7 +98,9 @@ func main() {
 
 	srv := api.NewServer(api.ServerConfig{
 		BluePortStart: bluePortStart,
+		Builds:        artifact.DefaultStore(),
 		DashboardPort: dashboardPort,
+		Health:        healthConfig(),
 		PostgresPort:  postgresPort,
 	}, logger)
 
@@ -95,14 +118,17 @@ func main() {
 	os.WriteFile(pidFile, []byte(fmt.Sprintf("%d", os.Getpid())), 0o644)
 	stateFile := filepath.Join(cheetahDir, "state.json")
 	srv.LoadState(stateFile)
+	go srv.GC()
 
 	e := echo.New()
 	e.HideBanner = true
 	e.HidePort = true
 	srv.Middleware(e)
 	srv.Routes(e)
+	e.POST("/mcp", echo.WrapHandler(mcp.New(api.NewClient(fmt.Sprintf("http://localhost:%d", dashboardPort)))))
 
 	go srv.PeriodicSave(stateFile, 5*time.Second)
+	go srv.MonitorHealth()
 
 	startErr := make(chan error, 1)
 	go func() {
@@ -134,6 +160,22 @@ func main() {
 	logger.Info("shutdown complete")
 }
 
+// healthConfig is the default probe config with overrides from env. Values
+// that aren't positive keep the default.
+func healthConfig() api.HealthConfig {
+	c := api.DefaultHealthConfig()
+	if v := config.EnvOr("CHEETAH_HEALTH_INTERVAL", c.Interval); v > 0 {
+		c.Interval = v
+	}
+	if v := config.EnvOr("CHEETAH_HEALTH_STATUS", c.ExpectStatus); v > 0 {
+		c.ExpectStatus = v
+	}
+	if v := config.EnvOr("CHEETAH_HEALTH_THRESHOLD", c.Threshold); v > 0 {
+		c.Threshold = v
+	}
+	return c
+}
+
 func status() {
 	url := fmt.Sprintf("http://localhost:%d/api/status", dashboardPort)
 	client := &http.Client{Timeout: time.Second}
@@ -166,6 +208,130 @@ func status() {
 	fmt.Printf("version:  %s\n", s.Version)
 }
 
+func gc() {
+	client := api.NewClient(fmt.Sprintf("http://localhost:%d", dashboardPort))
+	rec, err := client.GC()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gc: %s\n", err)
+		os.Exit(1)
+	}
+	for _, p := range rec.Paths {
+		fmt.Println("removed", p)
+	}
+	fmt.Printf("reclaimed %.1f MB in %d dirs\n", float64(rec.Bytes)/(1<<20), len(rec.Paths))
+}
+
+// rollback relaunches a kept build for a space, defaulting to the space of
+// the current directory and the build before the active one.
+func rollback(args []string) {
+	var space, id string
+	if len(args) > 0 {
+		space = args[0]
+	} else {
+		s, err := code.System()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rollback: %s\n", err)
+			os.Exit(1)
+		}
+		space = s.Name
+	}
+	if len(args) > 1 {
+		id = args[1]
+	}
+
+	client := api.NewClient(fmt.Sprintf("http://localhost:%d", dashboardPort))
+	app, err := client.AppGet(space)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rollback: %s\n", err)
+		os.Exit(1)
+	}
+
+	for _, a := range app.Artifacts {
+		marker := " "
+		if a.Active {
+			marker = "*"
+		}
+		fmt.Printf("%s %s  %-20s %s\n", marker, a.ID, a.Commit, a.BuiltAt.Format(time.DateTime))
+	}
+
+	if err := client.Rollback(space, id); err != nil {
+		fmt.Fprintf(os.Stderr, "rollback: %s\n", err)
+		os.Exit(1)
+	}
+	if id == "" {
+		id = "previous build"
+	}
+	fmt.Printf("rolling back %s to %s\n", space, id)
+}
+
+// test asks the runner of the current directory's space to run go test and
+// prints a summary of the failures, exiting 1 unless everything passed.
+func test(args []string) {
+	if len(args) == 1 && args[0] == "--flaky" {
+		flaky()
+		return
+	}
+
+	s, err := code.System()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "test: %s\n", err)
+		os.Exit(1)
+	}
+
+	client := api.NewClient(fmt.Sprintf("http://localhost:%d", dashboardPort))
+	run, err := client.TestStart(s.Name, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "test: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("testing %s...\n", s.Name)
+
+	queued := time.Now()
+	for run.Status == "queued" || run.Status == "running" {
+		if run.Status == "queued" && time.Since(queued) > 10*time.Second {
+			fmt.Fprintf(os.Stderr, "test: no runner picked up the run; is go run main.go running in %s?\n", s.Dir)
+			os.Exit(1)
+		}
+		time.Sleep(500 * time.Millisecond)
+		if run, err = client.TestGet(s.Name, run.ID); err != nil {
+			fmt.Fprintf(os.Stderr, "test: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Print(gotest.Summary(run))
+	if run.Status != "pass" {
+		os.Exit(1)
+	}
+}
+
+// flaky lists the tests in the test history that both passed and failed at
+// the same commit, most recent first.
+func flaky() {
+	outcomes, err := gotest.DefaultHistory().Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "test: %s\n", err)
+		os.Exit(1)
+	}
+	flakes := gotest.Flaky(outcomes)
+	if len(flakes) == 0 {
+		fmt.Println("no flaky tests")
+		return
+	}
+	for _, f := range flakes {
+		fmt.Printf("%s.%s  %d passed, %d failed at %d commits, last %s\n",
+			f.Package, f.Test, f.Passed, f.Failed, f.Commits, f.LastSeen.Format(time.DateTime))
+	}
+}
+
+func serveMCP() {
+	url := fmt.Sprintf("http://localhost:%d", dashboardPort)
+	if err := mcp.New(api.NewClient(url)).Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "mcp: %s\n", err)
+		os.Exit(1)
+	}
+}
+
 func pgStatus() string {
 	if pg.Dial() {
 		return fmt.Sprintf("running (localhost:%d)", postgresPort)
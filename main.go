@@ -40,26 +40,15 @@ func main() {
 
 	// Watch cheetah source files, ignoring child apps
 	cwd, _ := os.Getwd()
-	var (
-		restartTimer *time.Timer
-		timerMu      sync.Mutex
-	)
-
-	w := watch.New(cwd, []string{"*.go", "*.templ"}, []string{"apps"}, func(path string) {
-		timerMu.Lock()
-		defer timerMu.Unlock()
-		if restartTimer != nil {
-			restartTimer.Stop()
+	w := watch.NewBatch(cwd, []string{"*.go", "*.templ"}, []string{"apps"}, func(changes []watch.Change) {
+		rel := changes[0].Path
+		if r, err := filepath.Rel(cwd, rel); err == nil {
+			rel = r
 		}
-		restartTimer = time.AfterFunc(500*time.Millisecond, func() {
-			rel := path
-			if r, err := filepath.Rel(cwd, path); err == nil {
-				rel = r
-			}
-			logger.Info("restart", "path", rel)
-			runner.restart()
-		})
+		logger.Info("restart", "path", rel, "changes", len(changes))
+		runner.restart()
 	})
+	w.Quiet = 500 * time.Millisecond
 	w.Start()
 
 	quit := make(chan os.Signal, 1)
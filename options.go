@@ -0,0 +1,159 @@
+package cheetah
+
+import (
+	"time"
+
+	"github.com/housecat-inc/cheetah/pkg/api"
+	"github.com/housecat-inc/cheetah/pkg/build"
+)
+
+// DefaultMatch is what the runner watches unless WithMatch is given.
+var DefaultMatch = []string{".envrc", "*.go", "*.sql", "*.templ", "go.mod", "public/**", "static/**"}
+
+type Option func(*options)
+
+type options struct {
+	defaults    map[string]string
+	drainGrace  time.Duration
+	pipeline    []Rule
+	probe       api.Probe
+	processes   map[string]string
+	restart     bool
+	staticDirs  []string
+	targets     []build.Target
+	testArgs    []string
+	testRetries int
+	watch       api.Watch
+}
+
+// WithDefaults provides default config vars, like a .envrc.example.
+func WithDefaults(vars map[string]string) Option {
+	return func(o *options) {
+		o.defaults = vars
+	}
+}
+
+// WithDrainGrace sets how long the old process keeps serving in-flight
+// requests after a swap before it gets SIGTERM. Defaults to 10s.
+func WithDrainGrace(d time.Duration) Option {
+	return func(o *options) {
+		o.drainGrace = d
+	}
+}
+
+// WithHealthPath sets the path polled for liveness, /health by default.
+func WithHealthPath(path string) Option {
+	return func(o *options) {
+		o.probe.Path = path
+	}
+}
+
+// WithHealthRetries sets how many times a new process is checked before a
+// swap gives up. Checks run every 500ms, so the default of 30 allows 15s.
+func WithHealthRetries(n int) Option {
+	return func(o *options) {
+		o.probe.Retries = n
+	}
+}
+
+// WithHealthTimeout sets the timeout for each health and readiness check.
+func WithHealthTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.probe.Timeout = d
+	}
+}
+
+// WithIgnore adds patterns for files that never trigger a rebuild, e.g.
+// "**/testdata/**". See WithMatch for the syntax.
+func WithIgnore(patterns ...string) Option {
+	return func(o *options) {
+		o.watch.Ignore = append(o.watch.Ignore, patterns...)
+	}
+}
+
+// WithMatch replaces DefaultMatch with the patterns that trigger a rebuild.
+// Patterns without a slash match file names anywhere; others match paths
+// from the app dir, where "**" spans directories. A "!" prefix excludes
+// files an earlier pattern matched.
+func WithMatch(patterns ...string) Option {
+	return func(o *options) {
+		o.watch.Match = patterns
+	}
+}
+
+// WithPipeline adds rules ahead of DefaultPipeline, e.g. to rebuild with
+// go generate when protobufs change:
+//
+//	cheetah.WithPipeline(cheetah.Rule{Match: []string{"*.proto"}, Steps: []cheetah.Step{cheetah.StepGenerate, cheetah.StepBuild}})
+func WithPipeline(rules ...Rule) Option {
+	return func(o *options) {
+		o.pipeline = append(o.pipeline, rules...)
+	}
+}
+
+// WithProcess runs command with sh as a worker called name, like a
+// Procfile entry, overriding one from the Procfile or cheetah.yaml.
+func WithProcess(name, command string) Option {
+	return func(o *options) {
+		if o.processes == nil {
+			o.processes = map[string]string{}
+		}
+		o.processes[name] = command
+	}
+}
+
+// WithReadyPath sets a separate path that must return 200 before traffic
+// swaps to a new process, e.g. /readyz for apps that warm caches.
+func WithReadyPath(path string) Option {
+	return func(o *options) {
+		o.probe.ReadyPath = path
+	}
+}
+
+// WithRestart restarts the app with exponential backoff when it crashes.
+func WithRestart() Option {
+	return func(o *options) {
+		o.restart = true
+	}
+}
+
+// WithStaticDirs watches dirs of files the app serves from disk, like
+// "static" or "web/public". Changed stylesheets and images are swapped in
+// open pages without a rebuild or reload; other files reload the page.
+func WithStaticDirs(dirs ...string) Option {
+	return func(o *options) {
+		o.staticDirs = append(o.staticDirs, dirs...)
+	}
+}
+
+// WithTargets replaces the ./cmd/app build with targets, overriding the
+// targets in cheetah.yaml. They build concurrently; the first serves PORT
+// and the rest run alongside it as workers, e.g.
+//
+//	cheetah.WithTargets(build.Target{Package: "./cmd/api"}, build.Target{Package: "./cmd/worker", Tags: []string{"dev"}})
+func WithTargets(targets ...build.Target) Option {
+	return func(o *options) {
+		o.targets = append(o.targets, targets...)
+	}
+}
+
+// WithTestRetries reruns failed tests up to n times. Tests that pass on a
+// retry don't fail the run and are reported as flaky.
+func WithTestRetries(n int) Option {
+	return func(o *options) {
+		o.testRetries = n
+	}
+}
+
+// WithWatchTests runs go test with args, -short by default, after each
+// rebuild goes live, for only the packages the changed files affect: their
+// own and those that import them. Results show in the dashboard and the
+// page badge.
+func WithWatchTests(args ...string) Option {
+	return func(o *options) {
+		if len(args) == 0 {
+			args = []string{"-short"}
+		}
+		o.testArgs = args
+	}
+}
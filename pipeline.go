@@ -0,0 +1,179 @@
+package cheetah
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/housecat-inc/cheetah/pkg/build"
+	"github.com/housecat-inc/cheetah/pkg/pg"
+	"github.com/housecat-inc/cheetah/pkg/watch"
+)
+
+// Step is one stage of bringing the app up to date after files change.
+type Step string
+
+const (
+	StepConfig   Step = "config"   // sync .envrc
+	StepDeps     Step = "deps"     // sync go.mod
+	StepMigrate  Step = "migrate"  // re-template the database
+	StepSqlc     Step = "sqlc"     // sqlc generate
+	StepTempl    Step = "templ"    // templ generate for each changed file
+	StepGenerate Step = "generate" // go generate ./...
+	StepBuild    Step = "build"    // go build and swap
+	StepRestart  Step = "restart"  // swap to the current build
+	StepReload   Step = "reload"   // reload the browser
+	StepAsset    Step = "asset"    // swap stylesheets and images in the browser
+)
+
+// Rule maps files matching Match, with the syntax of WithMatch, to the steps
+// a change to them needs. The first matching rule applies, and files no
+// rule matches get a full go generate and build.
+type Rule struct {
+	Match []string
+	Steps []Step
+}
+
+// DefaultPipeline is the rules for the app in dir. Migration dirs and sqlc
+// queries come from sqlc.yaml when there is one. Without sqlc, SQL changes
+// only need a restart, and static files a reload, unless the app may embed
+// them with go:embed.
+func DefaultPipeline(dir string) []Rule {
+	rules := []Rule{
+		{Match: []string{".envrc"}, Steps: []Step{StepConfig, StepRestart}},
+		{Match: []string{"go.mod", "go.sum"}, Steps: []Step{StepDeps, StepGenerate, StepBuild}},
+		{Match: []string{"*.templ"}, Steps: []Step{StepTempl, StepBuild}},
+	}
+
+	sqlc := pg.HasSqlcConfig(dir)
+	embedded := embeds(dir)
+	sqlSteps := []Step{StepMigrate, StepRestart}
+	if sqlc || embedded {
+		sqlSteps = []Step{StepMigrate, StepBuild}
+	}
+	staticSteps := []Step{StepReload}
+	if embedded {
+		staticSteps = []Step{StepBuild}
+	}
+	if dirs, err := pg.MigrationDirs(dir); err == nil {
+		var match []string
+		for _, d := range dirs {
+			if rel, err := filepath.Rel(dir, d); err == nil {
+				match = append(match, filepath.ToSlash(rel)+"/**")
+			}
+		}
+		steps := sqlSteps
+		if sqlc {
+			steps = []Step{StepMigrate, StepSqlc, StepBuild}
+		}
+		rules = append(rules, Rule{Match: match, Steps: steps})
+	}
+	if sqlc {
+		rules = append(rules, Rule{Match: []string{"*.sql", "sqlc.yaml", "sqlc.yml"}, Steps: []Step{StepSqlc, StepBuild}})
+	} else {
+		rules = append(rules, Rule{Match: []string{"*.sql"}, Steps: sqlSteps})
+	}
+
+	return append(rules,
+		Rule{Match: []string{"public/**", "static/**"}, Steps: staticSteps},
+		Rule{Match: []string{"*.go"}, Steps: []Step{StepGenerate, StepBuild}},
+	)
+}
+
+// embeds reports whether any Go file in dir has a go:embed directive, or
+// whether dir can't be read to tell.
+func embeds(dir string) bool {
+	found := false
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != dir && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "testdata" || name == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(data, []byte("//go:embed")) {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found || err != nil
+}
+
+// plan is the union of steps for a batch of changes.
+type plan struct {
+	assets []string
+	steps  map[Step]bool
+	templ  []string
+}
+
+// newPlan runs rules over changes. Nil changes, as from the dashboard's
+// rebuild button, plan a full rebuild.
+func newPlan(rules []Rule, dir string, changes []watch.Change) plan {
+	p := plan{steps: map[Step]bool{}}
+	if changes == nil {
+		p.steps[StepGenerate] = true
+		p.steps[StepBuild] = true
+		return p
+	}
+
+	for _, c := range changes {
+		rel := c.Path
+		if r, err := filepath.Rel(dir, c.Path); err == nil {
+			rel = r
+		}
+		steps := []Step{StepGenerate, StepBuild}
+		for _, r := range rules {
+			if watch.MatchesAny(rel, r.Match) {
+				steps = r.Steps
+				break
+			}
+		}
+		for _, s := range steps {
+			p.steps[s] = true
+			switch s {
+			case StepAsset:
+				p.assets = append(p.assets, filepath.ToSlash(rel))
+			case StepTempl:
+				p.templ = append(p.templ, c.Path)
+			}
+		}
+	}
+	return p
+}
+
+func (p plan) has(s Step) bool {
+	return p.steps[s]
+}
+
+// generators lists the code generation the plan's build needs. Templ files
+// that are gone have their generated Go removed instead.
+func (p plan) generators() []build.Generator {
+	if p.has(StepGenerate) {
+		return []build.Generator{build.GoGenerate}
+	}
+	gens := []build.Generator{}
+	if p.has(StepSqlc) {
+		gens = append(gens, build.Sqlc())
+	}
+	for _, f := range p.templ {
+		if _, err := os.Stat(f); err != nil {
+			os.Remove(strings.TrimSuffix(f, ".templ") + "_templ.go")
+			continue
+		}
+		gens = append(gens, build.Templ(f))
+	}
+	return gens
+}
@@ -0,0 +1,121 @@
+package cheetah
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/housecat-inc/cheetah/pkg/watch"
+)
+
+func TestPlan(t *testing.T) {
+	sqlcDir := t.TempDir()
+	os.MkdirAll(filepath.Join(sqlcDir, "pkg", "db", "migrations"), 0o755)
+	os.WriteFile(filepath.Join(sqlcDir, "sqlc.yaml"), []byte("version: \"2\"\nsql:\n  - schema: \"pkg/db/migrations/\"\n    queries: \"pkg/db/query.sql\"\n"), 0o644)
+
+	plainDir := t.TempDir()
+
+	embedDir := t.TempDir()
+	os.MkdirAll(filepath.Join(embedDir, "migrations"), 0o755)
+	os.WriteFile(filepath.Join(embedDir, "main.go"), []byte("package main\n\nimport \"embed\"\n\n//go:embed migrations/*.sql\nvar migrations embed.FS\n"), 0o644)
+
+	tests := []struct {
+		_name string
+		dir   string
+		paths []string
+		out   []Step
+	}{
+		{_name: "go file", dir: plainDir, paths: []string{"pkg/app.go"}, out: []Step{StepBuild, StepGenerate}},
+		{_name: "templ file", dir: plainDir, paths: []string{"pkg/templates/home.templ"}, out: []Step{StepBuild, StepTempl}},
+		{_name: "envrc", dir: plainDir, paths: []string{".envrc"}, out: []Step{StepConfig, StepRestart}},
+		{_name: "go.mod", dir: plainDir, paths: []string{"go.mod"}, out: []Step{StepBuild, StepDeps, StepGenerate}},
+		{_name: "static asset", dir: plainDir, paths: []string{"static/app.css"}, out: []Step{StepReload}},
+		{_name: "unmatched", dir: plainDir, paths: []string{"api.proto"}, out: []Step{StepBuild, StepGenerate}},
+		{_name: "sql without sqlc", dir: plainDir, paths: []string{"schema.sql"}, out: []Step{StepMigrate, StepRestart}},
+		{_name: "embedded sql", dir: embedDir, paths: []string{"schema.sql"}, out: []Step{StepBuild, StepMigrate}},
+		{_name: "embedded migration", dir: embedDir, paths: []string{"migrations/002_add.sql"}, out: []Step{StepBuild, StepMigrate}},
+		{_name: "embedded static", dir: embedDir, paths: []string{"static/app.css"}, out: []Step{StepBuild}},
+		{_name: "sqlc query", dir: sqlcDir, paths: []string{"pkg/db/query.sql"}, out: []Step{StepBuild, StepSqlc}},
+		{_name: "sqlc migration", dir: sqlcDir, paths: []string{"pkg/db/migrations/002_add.sql"}, out: []Step{StepBuild, StepMigrate, StepSqlc}},
+		{_name: "union", dir: plainDir, paths: []string{"static/app.css", "main.go"}, out: []Step{StepBuild, StepGenerate, StepReload}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+
+			var changes []watch.Change
+			for _, p := range tt.paths {
+				changes = append(changes, watch.Change{Op: watch.OpModified, Path: filepath.Join(tt.dir, p)})
+			}
+			p := newPlan(DefaultPipeline(tt.dir), tt.dir, changes)
+
+			var out []Step
+			for s := range p.steps {
+				out = append(out, s)
+			}
+			a.ElementsMatch(tt.out, out)
+		})
+	}
+}
+
+func TestPlanGenerators(t *testing.T) {
+	dir := t.TempDir()
+	home := filepath.Join(dir, "home.templ")
+	gone := filepath.Join(dir, "gone.templ")
+	goneGo := filepath.Join(dir, "gone_templ.go")
+	os.WriteFile(home, nil, 0o644)
+	os.WriteFile(goneGo, nil, 0o644)
+
+	tests := []struct {
+		_name   string
+		changes []watch.Change
+		out     []string
+	}{
+		{_name: "manual rebuild", out: []string{"generate"}},
+		{_name: "go file", changes: []watch.Change{{Path: filepath.Join(dir, "main.go")}}, out: []string{"generate"}},
+		{_name: "asset only", changes: []watch.Change{{Path: filepath.Join(dir, "static", "app.css")}}, out: []string{}},
+		{
+			_name:   "templ files",
+			changes: []watch.Change{{Path: home}, {Op: watch.OpDeleted, Path: gone}},
+			out:     []string{"templ"},
+		},
+		{
+			_name:   "generate covers templ",
+			changes: []watch.Change{{Path: home}, {Path: filepath.Join(dir, "go.mod")}},
+			out:     []string{"generate"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+
+			out := []string{}
+			for _, g := range newPlan(DefaultPipeline(dir), dir, tt.changes).generators() {
+				out = append(out, g.Step)
+			}
+			a.Equal(tt.out, out)
+		})
+	}
+
+	_, err := os.Stat(goneGo)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPlanAssets(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	rules := append([]Rule{{Match: []string{"web/static/**"}, Steps: []Step{StepAsset}}}, DefaultPipeline(dir)...)
+	p := newPlan(rules, dir, []watch.Change{
+		{Op: watch.OpModified, Path: filepath.Join(dir, "web", "static", "app.css")},
+		{Op: watch.OpAdded, Path: filepath.Join(dir, "web", "static", "img", "logo.png")},
+	})
+
+	a.True(p.has(StepAsset))
+	a.False(p.has(StepBuild))
+	a.Equal([]string{"web/static/app.css", "web/static/img/logo.png"}, p.assets)
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	callbackDebounce = 2 * time.Second
+	callbackDedup    = time.Minute
+	callbackTimeout  = 10 * time.Second
+)
+
+const (
+	EventBuild = "build"
+	EventCrash = "crash"
+	EventLog   = "log"
+	EventSwap  = "swap"
+	EventTest  = "test"
+)
+
+type pendingCallback struct {
+	events []CallbackEvent
+	timer  *time.Timer
+}
+
+func (s *Server) callbackGet(space string) (Callback, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cb, ok := s.callbacks[space]
+	return cb, ok
+}
+
+// callbackSet sets the space's callback, or clears it when cb is empty. It
+// reports false for a space that isn't registered.
+func (s *Server) callbackSet(space string, cb Callback) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cb.URL == "" && cb.Command == "" {
+		delete(s.callbacks, space)
+		return true
+	}
+	if _, ok := s.apps[space]; !ok {
+		return false
+	}
+	s.callbacks[space] = cb
+	return true
+}
+
+// notify queues an event for the space's callback. Events are collected for
+// callbackDebounce after the first one arrives, then sent as one payload.
+// Identical events seen within callbackDedup of the last delivery are dropped.
+func (s *Server) notify(space string, ev CallbackEvent) {
+	if _, ok := s.callbackGet(space); !ok {
+		return
+	}
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now()
+	}
+	ev.Count = 1
+
+	s.cbMu.Lock()
+	defer s.cbMu.Unlock()
+
+	key := space + "\x00" + ev.Kind + "\x00" + ev.Message
+	if last, ok := s.cbSent[key]; ok && time.Since(last) < callbackDedup {
+		return
+	}
+
+	p := s.cbPending[space]
+	if p == nil {
+		p = &pendingCallback{}
+		s.cbPending[space] = p
+		p.timer = time.AfterFunc(s.callbackDebounce, func() { s.flushCallback(space) })
+	}
+	for i := range p.events {
+		if p.events[i].Kind == ev.Kind && p.events[i].Message == ev.Message {
+			p.events[i].Count++
+			return
+		}
+	}
+	p.events = append(p.events, ev)
+}
+
+// dropCallbacks stops the space's pending callback and forgets what it sent,
+// so a later app registering the same space starts clean.
+func (s *Server) dropCallbacks(space string) {
+	s.cbMu.Lock()
+	defer s.cbMu.Unlock()
+	if p := s.cbPending[space]; p != nil {
+		p.timer.Stop()
+		delete(s.cbPending, space)
+	}
+	for key := range s.cbSent {
+		if strings.HasPrefix(key, space+"\x00") {
+			delete(s.cbSent, key)
+		}
+	}
+}
+
+func (s *Server) flushCallback(space string) {
+	s.cbMu.Lock()
+	p := s.cbPending[space]
+	delete(s.cbPending, space)
+	now := time.Now()
+	for key, last := range s.cbSent {
+		if now.Sub(last) >= callbackDedup {
+			delete(s.cbSent, key)
+		}
+	}
+	if p != nil {
+		for _, ev := range p.events {
+			s.cbSent[space+"\x00"+ev.Kind+"\x00"+ev.Message] = now
+		}
+	}
+	s.cbMu.Unlock()
+
+	cb, ok := s.callbackGet(space)
+	if !ok || p == nil || len(p.events) == 0 {
+		return
+	}
+
+	payload := CallbackPayload{Events: p.events, Space: space}
+	if app, ok := s.get(space); ok {
+		payload.Dir = app.Dir
+	}
+	if err := fireCallback(cb, payload); err != nil {
+		s.logger.Warn("callback failed", "space", space, "error", err)
+		return
+	}
+	s.logger.Info("callback", "space", space, "events", len(p.events))
+}
+
+func fireCallback(cb Callback, payload CallbackPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	if cb.URL != "" {
+		c := &http.Client{Timeout: callbackTimeout}
+		resp, err := c.Post(cb.URL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("callback url returned %s", resp.Status)
+		}
+	}
+
+	if cb.Command != "" {
+		cmd := exec.Command("sh", "-c", cb.Command)
+		cmd.Dir = payload.Dir
+		cmd.Env = append(os.Environ(), "SPACE="+payload.Space)
+		cmd.Stdin = bytes.NewReader(body)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		done := make(chan error, 1)
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		go func() { done <- cmd.Wait() }()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(callbackTimeout):
+			cmd.Process.Kill()
+			return fmt.Errorf("callback command timed out after %s", callbackTimeout)
+		}
+	}
+
+	return nil
+}
+
+func buildEvent(build BuildResult) CallbackEvent {
+	msg := fmt.Sprintf("%s failed", build.Step)
+	if len(build.Errors) > 0 {
+		e := build.Errors[0]
+		msg = e.Message
+		if e.File != "" {
+			msg = errorLocation(e) + " " + e.Message
+		}
+	}
+	return CallbackEvent{Build: &build, Kind: EventBuild, Message: msg, Timestamp: build.FinishedAt}
+}
+
+func crashEvent(crash Crash) CallbackEvent {
+	msg := fmt.Sprintf("app exited with code %d", crash.ExitCode)
+	if crash.Signal != "" {
+		msg = fmt.Sprintf("app killed by %s", crash.Signal)
+	}
+	return CallbackEvent{Crash: &crash, Kind: EventCrash, Message: msg, Timestamp: crash.At}
+}
+
+// testEvent names the first failing test; the full run is on the event.
+func testEvent(run TestRun) CallbackEvent {
+	msg := fmt.Sprintf("go test %s", run.Status)
+	for _, t := range run.Tests {
+		if t.Status == "fail" {
+			msg = fmt.Sprintf("%d failed: %s %s", run.Failed, t.Package, t.Test)
+			break
+		}
+	}
+	return CallbackEvent{Kind: EventTest, Message: strings.TrimSpace(msg), Test: &run, Timestamp: run.FinishedAt}
+}
+
+func logEvent(l Log) CallbackEvent {
+	kind := EventLog
+	if k, ok := l.Attrs["event"].(string); ok && k != "" {
+		kind = k
+	}
+	return CallbackEvent{Kind: kind, Log: &l, Message: l.Message, Timestamp: l.Timestamp}
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"cmp"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifyDebouncesAndDedupes(t *testing.T) {
+	a := assert.New(t)
+
+	var (
+		mu       sync.Mutex
+		payloads []CallbackPayload
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p CallbackPayload
+		json.NewDecoder(r.Body).Decode(&p)
+		mu.Lock()
+		payloads = append(payloads, p)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	srv := NewServer(ServerConfig{BluePortStart: 4000, DashboardPort: 50000, PostgresPort: 54320}, slog.Default())
+	srv.callbackDebounce = 20 * time.Millisecond
+	srv.register(AppIn{Space: "buffalo", Dir: t.TempDir(), Callback: &Callback{URL: hook.URL}})
+
+	srv.notify("buffalo", CallbackEvent{Kind: EventLog, Message: "boom"})
+	srv.notify("buffalo", CallbackEvent{Kind: EventLog, Message: "boom"})
+	srv.notify("buffalo", buildEvent(BuildResult{Errors: []BuildError{{File: "main.go", Line: 3, Message: "undefined: x", Tool: "go"}}, Status: "failed", Step: "build"}))
+	srv.notify("manama", CallbackEvent{Kind: EventLog, Message: "no callback registered"})
+
+	a.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(payloads) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	p := payloads[0]
+	mu.Unlock()
+	a.Equal("buffalo", p.Space)
+	a.Len(p.Events, 2)
+	a.Equal(2, p.Events[0].Count)
+	a.Equal(EventBuild, p.Events[1].Kind)
+	a.Equal("main.go:3: undefined: x", p.Events[1].Message)
+
+	srv.notify("buffalo", CallbackEvent{Kind: EventLog, Message: "boom"})
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	a.Len(payloads, 1)
+	mu.Unlock()
+}
+
+func TestCallbackPut(t *testing.T) {
+	tests := []struct {
+		_name string
+		body  string
+		space string
+		out   int
+	}{
+		{_name: "registered", body: `{"url":"http://localhost:9000/hook"}`, space: "buffalo", out: http.StatusNoContent},
+		{_name: "unknown space", body: `{"url":"http://localhost:9000/hook"}`, space: "manama", out: http.StatusNotFound},
+		{_name: "empty", body: `{}`, space: "buffalo", out: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+
+			srv := NewServer(ServerConfig{BluePortStart: 4000, DashboardPort: 50000, PostgresPort: 54320}, slog.Default())
+			srv.register(AppIn{Space: "buffalo", Dir: t.TempDir()})
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("space")
+			c.SetParamValues(tt.space)
+
+			a.NoError(srv.handleCallbackPut(c))
+			a.Equal(tt.out, rec.Code)
+			_, ok := srv.callbackGet(tt.space)
+			a.Equal(tt.out == http.StatusNoContent, ok)
+		})
+	}
+}
+
+func TestCallbackPutLocalOnly(t *testing.T) {
+	tests := []struct {
+		_name       string
+		contentType string
+		origin      string
+		remoteAddr  string
+		out         int
+	}{
+		{_name: "runner", out: http.StatusNoContent},
+		{_name: "dashboard", origin: "http://localhost:50000", out: http.StatusNoContent},
+		{_name: "cross origin", origin: "https://evil.example", out: http.StatusForbidden},
+		{_name: "app space", origin: "http://buffalo.localhost:50000", out: http.StatusForbidden},
+		{_name: "remote", remoteAddr: "192.168.1.20:5555", out: http.StatusForbidden},
+		{_name: "form post", contentType: "text/plain", out: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+
+			srv := NewServer(ServerConfig{BluePortStart: 4000, DashboardPort: 50000, PostgresPort: 54320}, slog.Default())
+			srv.register(AppIn{Space: "buffalo", Dir: t.TempDir()})
+			e := echo.New()
+			srv.Routes(e)
+
+			req := httptest.NewRequest(http.MethodPut, "/api/apps/buffalo/callback", strings.NewReader(`{"command":"touch pwned"}`))
+			req.RemoteAddr = cmp.Or(tt.remoteAddr, "127.0.0.1:5555")
+			req.Header.Set("Content-Type", cmp.Or(tt.contentType, "application/json"))
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			a.Equal(tt.out, rec.Code)
+			_, ok := srv.callbackGet("buffalo")
+			a.Equal(tt.out == http.StatusNoContent, ok)
+		})
+	}
+}
+
+func TestDeregisterDropsCallback(t *testing.T) {
+	a := assert.New(t)
+
+	srv := NewServer(ServerConfig{BluePortStart: 4000, DashboardPort: 50000, PostgresPort: 54320}, slog.Default())
+	srv.register(AppIn{Space: "buffalo", Dir: t.TempDir(), Callback: &Callback{Command: "cat"}})
+	srv.notify("buffalo", CallbackEvent{Kind: EventLog, Message: "boom"})
+	a.True(srv.deregister("buffalo"))
+
+	_, ok := srv.callbackGet("buffalo")
+	a.False(ok)
+	srv.cbMu.Lock()
+	a.Empty(srv.cbPending)
+	srv.cbMu.Unlock()
+
+	srv.register(AppIn{Space: "buffalo", Dir: t.TempDir()})
+	_, ok = srv.callbackGet("buffalo")
+	a.False(ok)
+}
+
+func TestCallbackCommand(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "payload.json")
+
+	err := fireCallback(Callback{Command: "cat > payload.json"}, CallbackPayload{
+		Dir:    dir,
+		Events: []CallbackEvent{logEvent(Log{Attrs: map[string]any{"event": EventSwap}, Level: "error", Message: "swap failed"})},
+		Space:  "buffalo",
+	})
+	r.NoError(err)
+
+	data, err := os.ReadFile(out)
+	r.NoError(err)
+	var p CallbackPayload
+	r.NoError(json.Unmarshal(data, &p))
+	a.Equal("buffalo", p.Space)
+	a.Equal(EventSwap, p.Events[0].Kind)
+}
@@ -3,9 +3,14 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"io"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/cockroachdb/errors"
+
+	"github.com/housecat-inc/cheetah/pkg/artifact"
 )
 
 type Client struct {
@@ -16,6 +21,65 @@ func NewClient(url string) *Client {
 	return &Client{URL: url}
 }
 
+func (c *Client) Action(space, action string) error {
+	res, err := http.Post(c.URL+"/api/apps/"+space+"/"+action, "application/json", nil)
+	if err != nil {
+		return errors.Wrap(err, "post")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		return errors.Newf("%s failed: %s", action, res.Status)
+	}
+	return nil
+}
+
+// Rollback asks the space's runner to relaunch a kept build. An empty id
+// means the build before the active one.
+func (c *Client) Rollback(space, id string) error {
+	body, _ := json.Marshal(map[string]string{"build_id": id})
+	res, err := http.Post(c.URL+"/api/apps/"+space+"/rollback", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "post")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		return errors.Newf("rollback failed: %s", res.Status)
+	}
+	return nil
+}
+
+// TestStart queues a go test run with optional go test args for the
+// space's runner. Poll TestGet with the returned ID for the result.
+func (c *Client) TestStart(space string, args []string) (TestRun, error) {
+	var run TestRun
+	body, _ := json.Marshal(map[string][]string{"args": args})
+	res, err := http.Post(c.URL+"/api/apps/"+space+"/tests", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return run, errors.Wrap(err, "post")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		return run, errors.Newf("test failed: %s", res.Status)
+	}
+	if err := json.NewDecoder(res.Body).Decode(&run); err != nil {
+		return run, errors.Wrap(err, "decode")
+	}
+	return run, nil
+}
+
+func (c *Client) TestGet(space, id string) (TestRun, error) {
+	var run TestRun
+	err := c.get("/api/apps/"+space+"/tests/"+id, &run)
+	return run, err
+}
+
+func (c *Client) TestPut(space string, run TestRun) {
+	body, _ := json.Marshal(run)
+	req, _ := http.NewRequest(http.MethodPut, c.URL+"/api/apps/"+space+"/tests/"+run.ID, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	http.DefaultClient.Do(req)
+}
+
 func (c *Client) AppDelete(space string) {
 	req, _ := http.NewRequest(http.MethodDelete, c.URL+"/api/apps/"+space, nil)
 	http.DefaultClient.Do(req)
@@ -43,6 +107,53 @@ func (c *Client) AppPost(req AppIn) (*AppOut, error) {
 	return &out, nil
 }
 
+func (c *Client) AppGet(space string) (*App, error) {
+	var app App
+	if err := c.get("/api/apps/"+space, &app); err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+func (c *Client) AppList() ([]App, error) {
+	var apps []App
+	if err := c.get("/api/apps", &apps); err != nil {
+		return nil, err
+	}
+	return apps, nil
+}
+
+func (c *Client) ArtifactsPut(space string, artifacts []Artifact) {
+	body, _ := json.Marshal(artifacts)
+	req, _ := http.NewRequest(http.MethodPut, c.URL+"/api/apps/"+space+"/artifacts", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	http.DefaultClient.Do(req)
+}
+
+func (c *Client) ProcessesPut(space string, procs []Process) {
+	body, _ := json.Marshal(procs)
+	req, _ := http.NewRequest(http.MethodPut, c.URL+"/api/apps/"+space+"/processes", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	http.DefaultClient.Do(req)
+}
+
+func (c *Client) BuildPost(space string, build BuildResult) {
+	body, _ := json.Marshal(build)
+	http.Post(c.URL+"/api/apps/"+space+"/builds", "application/json", bytes.NewReader(body))
+}
+
+// Reload tells browsers on the space to reload without a rebuild.
+func (c *Client) Reload(space string) {
+	http.Post(c.URL+"/api/apps/"+space+"/reload", "application/json", nil)
+}
+
+// Assets tells browsers on the space to swap changed stylesheets and
+// images in place.
+func (c *Client) Assets(space string, paths []string) {
+	body, _ := json.Marshal(Assets{Paths: paths, Space: space})
+	http.Post(c.URL+"/api/apps/"+space+"/assets", "application/json", bytes.NewReader(body))
+}
+
 func (c *Client) LogPost(space string, entries []Log) {
 	body, _ := json.Marshal(entries)
 	http.Post(c.URL+"/api/apps/"+space+"/logs", "application/json", bytes.NewReader(body))
@@ -57,3 +168,96 @@ func (c *Client) HealthUpdate(space string, portActive int, status string) {
 	req.Header.Set("Content-Type", "application/json")
 	http.DefaultClient.Do(req)
 }
+
+// Drain asks the proxy to retire port, waiting up to grace for in-flight
+// requests. It returns how many requests were still open.
+func (c *Client) Drain(space string, port int, grace time.Duration) (int, error) {
+	body, _ := json.Marshal(map[string]any{
+		"grace": grace,
+		"port":  port,
+	})
+	res, err := http.Post(c.URL+"/api/apps/"+space+"/drain", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, errors.Wrap(err, "post")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return 0, errors.Newf("drain failed: %s", res.Status)
+	}
+	var out struct {
+		Remaining int `json:"remaining"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return 0, errors.Wrap(err, "decode")
+	}
+	return out.Remaining, nil
+}
+
+// GC asks cheetah to remove build artifacts no space keeps anymore.
+func (c *Client) GC() (artifact.Reclaimed, error) {
+	var rec artifact.Reclaimed
+	res, err := http.Post(c.URL+"/api/gc", "application/json", nil)
+	if err != nil {
+		return rec, errors.Wrap(err, "post")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return rec, errors.Newf("gc failed: %s", res.Status)
+	}
+	if err := json.NewDecoder(res.Body).Decode(&rec); err != nil {
+		return rec, errors.Wrap(err, "decode")
+	}
+	return rec, nil
+}
+
+func (c *Client) EnvGet(app string) (map[string]string, error) {
+	vars := map[string]string{}
+	if err := c.get("/api/env/"+url.PathEscape(app), &vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+func (c *Client) EnvPut(app string, vars map[string]string) error {
+	body, err := json.Marshal(vars)
+	if err != nil {
+		return errors.Wrap(err, "marshal")
+	}
+	req, _ := http.NewRequest(http.MethodPut, c.URL+"/api/env/"+url.PathEscape(app), bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "put")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		return errors.Newf("env put failed: %s", res.Status)
+	}
+	return nil
+}
+
+func (c *Client) get(path string, out any) error {
+	res, err := http.Get(c.URL + path)
+	if err != nil {
+		return errors.Wrap(err, "get")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(res.Body)
+		return errors.Newf("get %s: %s %s", path, res.Status, bytes.TrimSpace(body))
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return errors.Wrap(err, "decode")
+	}
+	return nil
+}
+
+func (c *Client) CrashUpdate(space string, status string, crash Crash) {
+	body, _ := json.Marshal(map[string]any{
+		"crash":  crash,
+		"status": status,
+	})
+	req, _ := http.NewRequest(http.MethodPut, c.URL+"/api/apps/"+space+"/health", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	http.DefaultClient.Do(req)
+}
@@ -31,7 +31,7 @@ func Dashboard(port int, status Status) templ.Component {
 			templ_7745c5c3_Var1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<!doctype html><html><head><title>Cheetah Dashboard</title><meta charset=\"utf-8\"><style>\n\t\t\t\tbody { font-family: system-ui, sans-serif; margin: 2rem; background: #0a0a0a; color: #e0e0e0; }\n\t\t\t\th1 { color: #f0f0f0; }\n\t\t\t\t.status { background: #1a1a2e; padding: 1rem; border-radius: 8px; margin-bottom: 2rem; }\n\t\t\t\t.status span { margin-right: 2rem; }\n\t\t\t\t.dot { display: inline-block; width: 10px; height: 10px; border-radius: 50%; margin-right: 4px; }\n\t\t\t\t.dot.on { background: #4ade80; }\n\t\t\t\t.dot.off { background: #ef4444; }\n\t\t\t\ttable { width: 100%; border-collapse: collapse; }\n\t\t\t\tth, td { text-align: left; padding: 0.5rem 1rem; border-bottom: 1px solid #2a2a3e; }\n\t\t\t\tth { color: #888; font-weight: 500; font-size: 0.85rem; text-transform: uppercase; }\n\t\t\t\ttr:hover { background: #1a1a2e; }\n\t\t\t\t.active-port { color: #4ade80; font-weight: 600; }\n\t\t\t\ta { color: #7dd3fc; text-decoration: none; }\n\t\t\t\ta:hover { text-decoration: underline; }\n\t\t\t\tcode { background: #1a1a2e; padding: 2px 6px; border-radius: 4px; font-size: 0.85rem; }\n\t\t\t\t.empty { text-align: center; padding: 3rem; color: #666; }\n\t\t\t\t#env-section { margin-top: 2rem; }\n\t\t\t\t#env-section h2 { color: #f0f0f0; font-size: 1.2rem; margin-bottom: 1rem; display: flex; align-items: center; gap: 1rem; }\n\t\t\t\t.env-group { background: #1a1a2e; border-radius: 8px; margin-bottom: 1rem; overflow: hidden; }\n\t\t\t\t.env-group-header { padding: 0.75rem 1rem; cursor: pointer; display: flex; align-items: center; gap: 0.5rem; user-select: none; }\n\t\t\t\t.env-group-header:hover { background: #2a2a3e; }\n\t\t\t\t.env-group-header .arrow { transition: transform 0.2s; font-size: 0.7rem; color: #888; }\n\t\t\t\t.env-group-header .arrow.open { transform: rotate(90deg); }\n\t\t\t\t.env-group-header .app-name { font-weight: 600; }\n\t\t\t\t.env-group-header .count { color: #888; font-size: 0.85rem; margin-left: auto; }\n\t\t\t\t.env-group-body { display: none; padding: 0 1rem 0.75rem; }\n\t\t\t\t.env-group-body.open { display: block; }\n\t\t\t\t.env-textarea { width: 100%; min-height: 120px; background: #0a0a0a; border: 1px solid #2a2a3e; color: #e0e0e0; padding: 0.6rem; border-radius: 4px; font: 0.85rem/1.4 monospace; resize: vertical; box-sizing: border-box; }\n\t\t\t\t.env-textarea:focus { border-color: #4a4a6e; outline: none; }\n\t\t\t\t.env-btn { background: #2a2a3e; border: 1px solid #3a3a4e; color: #e0e0e0; padding: 0.4rem 0.8rem; border-radius: 4px; cursor: pointer; font-size: 0.85rem; }\n\t\t\t\t.env-btn:hover { background: #3a3a4e; }\n\t\t\t\t.env-btn.danger { color: #ef4444; }\n\t\t\t\t.env-btn.danger:hover { background: #3a1a1a; }\n\t\t\t\t.env-actions { display: flex; gap: 0.5rem; margin-top: 0.5rem; }\n\t\t\t\t.env-saved { color: #4ade80; font-size: 0.85rem; opacity: 0; transition: opacity 0.3s; }\n\t\t\t\t.env-saved.show { opacity: 1; }\n\t\t\t\t.env-modal-overlay { position: fixed; top: 0; left: 0; right: 0; bottom: 0; background: rgba(0,0,0,0.6); z-index: 10000; display: flex; align-items: center; justify-content: center; }\n\t\t\t\t.env-modal { background: #1a1a2e; border: 1px solid #2a2a3e; border-radius: 8px; padding: 1.5rem; width: 480px; max-width: 90vw; }\n\t\t\t\t.env-modal h3 { margin: 0 0 1rem; color: #f0f0f0; font-size: 1.1rem; }\n\t\t\t\t.env-modal label { display: block; color: #888; font-size: 0.85rem; margin-bottom: 0.3rem; }\n\t\t\t\t.env-modal input, .env-modal textarea { width: 100%; box-sizing: border-box; background: #0a0a0a; border: 1px solid #2a2a3e; color: #e0e0e0; padding: 0.5rem; border-radius: 4px; font: 0.85rem/1.4 monospace; margin-bottom: 0.75rem; }\n\t\t\t\t.env-modal textarea { min-height: 100px; resize: vertical; }\n\t\t\t\t.env-modal-actions { display: flex; gap: 0.5rem; justify-content: flex-end; }\n\t\t\t\t.env-modal .env-error { color: #ef4444; font-size: 0.85rem; margin-bottom: 0.5rem; min-height: 1.2em; }\n\t\t\t</style></head><body><h1>Cheetah</h1><div class=\"status\" id=\"status-bar\"><span>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<!doctype html><html><head><title>Cheetah Dashboard</title><meta charset=\"utf-8\"><style>\n\t\t\t\tbody { font-family: system-ui, sans-serif; margin: 2rem; background: #0a0a0a; color: #e0e0e0; }\n\t\t\t\th1 { color: #f0f0f0; }\n\t\t\t\t.status { background: #1a1a2e; padding: 1rem; border-radius: 8px; margin-bottom: 2rem; }\n\t\t\t\t.status span { margin-right: 2rem; }\n\t\t\t\t.dot { display: inline-block; width: 10px; height: 10px; border-radius: 50%; margin-right: 4px; }\n\t\t\t\t.dot.on { background: #4ade80; }\n\t\t\t\t.dot.off { background: #ef4444; }\n\t\t\t\ttable { width: 100%; border-collapse: collapse; }\n\t\t\t\tth, td { text-align: left; padding: 0.5rem 1rem; border-bottom: 1px solid #2a2a3e; }\n\t\t\t\tth { color: #888; font-weight: 500; font-size: 0.85rem; text-transform: uppercase; }\n\t\t\t\ttr:hover { background: #1a1a2e; }\n\t\t\t\t.active-port { color: #4ade80; font-weight: 600; }\n\t\t\t\ta { color: #7dd3fc; text-decoration: none; }\n\t\t\t\ta:hover { text-decoration: underline; }\n\t\t\t\tcode { background: #1a1a2e; padding: 2px 6px; border-radius: 4px; font-size: 0.85rem; }\n\t\t\t\t.empty { text-align: center; padding: 3rem; color: #666; }\n\t\t\t\t.logs-toggle { cursor: pointer; color: #7dd3fc; }\n\t\t\t\t.logs-row td { padding: 0 1rem 0.75rem; }\n\t\t\t\t.logs { background: #0a0a0a; border: 1px solid #2a2a3e; border-radius: 4px; padding: 0.5rem; margin: 0; max-height: 240px; overflow: auto; font: 0.8rem/1.4 monospace; white-space: pre-wrap; }\n\t\t\t\t.logs .debug { color: #666; }\n\t\t\t\t.logs .warn { color: #facc15; }\n\t\t\t\t.logs .error { color: #ef4444; }\n\t\t\t\t.logs .attrs { color: #888; }\n\t\t\t\t.hc { display: inline-block; width: 4px; height: 12px; margin-right: 1px; border-radius: 1px; background: #4ade80; vertical-align: middle; }\n\t\t\t\t.hc.fail { background: #ef4444; }\n\t\t\t\t.health-status { font-size: 0.85rem; margin-right: 0.5rem; }\n\t\t\t\t.builds { width: auto; margin: 0; font-size: 0.85rem; }\n\t\t\t\t.builds td { padding: 0.25rem 1rem 0.25rem 0; border: none; }\n\t\t\t\t.build-active { color: #4ade80; }\n\t\t\t\t.watch-ignore { color: #888; font-size: 0.8rem; }\n\t\t\t\t.build-status { margin-left: 0.5rem; color: #888; font-size: 0.8rem; }\n\t\t\t\t.build-status.failed { color: #ef4444; }\n\t\t\t\t.crash { color: #ef4444; font-size: 0.8rem; margin-left: 0.5rem; }\n\t\t\t\t.proc { margin-right: 0.5rem; }\n\t\t\t\t.test-status.fail, .test-status.error { color: #ef4444; }\n\t\t\t\t.test-status.pass { color: #22c55e; }\n\t\t\t\t.test-status.flaky { color: #facc15; }\n\t\t\t\tpre.test-output { margin: 0.25rem 0 0.75rem; white-space: pre-wrap; }\n\t\t\t\t.proc.crashed { color: #ef4444; }\n\t\t\t\t#env-section { margin-top: 2rem; }\n\t\t\t\t#env-section h2 { color: #f0f0f0; font-size: 1.2rem; margin-bottom: 1rem; display: flex; align-items: center; gap: 1rem; }\n\t\t\t\t.env-group { background: #1a1a2e; border-radius: 8px; margin-bottom: 1rem; overflow: hidden; }\n\t\t\t\t.env-group-header { padding: 0.75rem 1rem; cursor: pointer; display: flex; align-items: center; gap: 0.5rem; user-select: none; }\n\t\t\t\t.env-group-header:hover { background: #2a2a3e; }\n\t\t\t\t.env-group-header .arrow { transition: transform 0.2s; font-size: 0.7rem; color: #888; }\n\t\t\t\t.env-group-header .arrow.open { transform: rotate(90deg); }\n\t\t\t\t.env-group-header .app-name { font-weight: 600; }\n\t\t\t\t.env-group-header .count { color: #888; font-size: 0.85rem; margin-left: auto; }\n\t\t\t\t.env-group-body { display: none; padding: 0 1rem 0.75rem; }\n\t\t\t\t.env-group-body.open { display: block; }\n\t\t\t\t.env-textarea { width: 100%; min-height: 120px; background: #0a0a0a; border: 1px solid #2a2a3e; color: #e0e0e0; padding: 0.6rem; border-radius: 4px; font: 0.85rem/1.4 monospace; resize: vertical; box-sizing: border-box; }\n\t\t\t\t.env-textarea:focus { border-color: #4a4a6e; outline: none; }\n\t\t\t\t.env-btn { background: #2a2a3e; border: 1px solid #3a3a4e; color: #e0e0e0; padding: 0.4rem 0.8rem; border-radius: 4px; cursor: pointer; font-size: 0.85rem; }\n\t\t\t\t.env-btn:hover { background: #3a3a4e; }\n\t\t\t\t.env-btn.danger { color: #ef4444; }\n\t\t\t\t.env-btn.danger:hover { background: #3a1a1a; }\n\t\t\t\t.env-actions { display: flex; gap: 0.5rem; margin-top: 0.5rem; }\n\t\t\t\t.env-saved { color: #4ade80; font-size: 0.85rem; opacity: 0; transition: opacity 0.3s; }\n\t\t\t\t.env-saved.show { opacity: 1; }\n\t\t\t\t.env-modal-overlay { position: fixed; top: 0; left: 0; right: 0; bottom: 0; background: rgba(0,0,0,0.6); z-index: 10000; display: flex; align-items: center; justify-content: center; }\n\t\t\t\t.env-modal { background: #1a1a2e; border: 1px solid #2a2a3e; border-radius: 8px; padding: 1.5rem; width: 480px; max-width: 90vw; }\n\t\t\t\t.env-modal h3 { margin: 0 0 1rem; color: #f0f0f0; font-size: 1.1rem; }\n\t\t\t\t.env-modal label { display: block; color: #888; font-size: 0.85rem; margin-bottom: 0.3rem; }\n\t\t\t\t.env-modal input, .env-modal textarea { width: 100%; box-sizing: border-box; background: #0a0a0a; border: 1px solid #2a2a3e; color: #e0e0e0; padding: 0.5rem; border-radius: 4px; font: 0.85rem/1.4 monospace; margin-bottom: 0.75rem; }\n\t\t\t\t.env-modal textarea { min-height: 100px; resize: vertical; }\n\t\t\t\t.env-modal-actions { display: flex; gap: 0.5rem; justify-content: flex-end; }\n\t\t\t\t.env-modal .env-error { color: #ef4444; font-size: 0.85rem; margin-bottom: 0.5rem; min-height: 1.2em; }\n\t\t\t</style></head><body><h1>Cheetah</h1><div class=\"status\" id=\"status-bar\"><span>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -53,7 +53,7 @@ func Dashboard(port int, status Status) templ.Component {
 		var templ_7745c5c3_Var2 string
 		templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprint(status.PostgresPort))
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `dashboard.templ`, Line: 67, Col: 48}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/api/dashboard.templ`, Line: 90, Col: 48}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
 		if templ_7745c5c3_Err != nil {
@@ -66,7 +66,7 @@ func Dashboard(port int, status Status) templ.Component {
 		var templ_7745c5c3_Var3 string
 		templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprint(status.AppCount))
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `dashboard.templ`, Line: 69, Col: 66}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/api/dashboard.templ`, Line: 92, Col: 66}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
 		if templ_7745c5c3_Err != nil {
@@ -79,7 +79,7 @@ func Dashboard(port int, status Status) templ.Component {
 		var templ_7745c5c3_Var4 string
 		templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(status.Version)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `dashboard.templ`, Line: 70, Col: 83}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/api/dashboard.templ`, Line: 93, Col: 83}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
 		if templ_7745c5c3_Err != nil {
@@ -100,7 +100,7 @@ func Dashboard(port int, status Status) templ.Component {
 		var templ_7745c5c3_Var5 string
 		templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprint(port))
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `dashboard.templ`, Line: 75, Col: 77}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/api/dashboard.templ`, Line: 98, Col: 77}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
 		if templ_7745c5c3_Err != nil {
@@ -113,7 +113,7 @@ func Dashboard(port int, status Status) templ.Component {
 		var templ_7745c5c3_Var6 string
 		templ_7745c5c3_Var6, templ_7745c5c3_Err = templ.JoinStringErrs(status.Version)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `dashboard.templ`, Line: 75, Col: 109}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/api/dashboard.templ`, Line: 98, Col: 109}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var6))
 		if templ_7745c5c3_Err != nil {
@@ -132,6 +132,133 @@ const dashboardJS = `<script>
   const table = document.getElementById("app-table");
   const countEl = document.getElementById("app-count");
   let apps = {};
+  const maxLogs = 100; // maxRecentLogs on the server
+  let openBuilds = {};
+  let openLogs = {};
+  let openTests = {};
+
+  function esc(s) {
+    return String(s).replace(/[&<>"']/g, c => ({"&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;", "'": "&#39;"})[c]);
+  }
+
+  function renderLogs(logs) {
+    if (logs.length === 0) return '<pre class="logs">No logs yet.</pre>';
+    let h = '<pre class="logs">';
+    for (const l of logs) {
+      const t = new Date(l.timestamp).toLocaleTimeString();
+      const attrs = Object.entries(l.attrs || {}).map(([k, v]) => k + "=" + (typeof v === "string" ? v : JSON.stringify(v))).join(" ");
+      h += '<div class="' + esc(l.level) + '">' + esc(t) + ' ' + esc(l.level.toUpperCase()) + ' ' + esc(l.message) +
+        (attrs ? ' <span class="attrs">' + esc(attrs) + '</span>' : '') + '</div>';
+    }
+    return h + '</pre>';
+  }
+
+  function renderCrash(a) {
+    const c = a.health && a.health.crash;
+    if (!c || (a.health.status !== 'crashed' && a.health.status !== 'crashloop')) return '';
+    const why = c.signal ? c.signal : 'exit ' + c.exit_code;
+    const title = (c.output || []).join('\n');
+    return '<span class="crash" title="' + esc(title) + '">' + esc(a.health.status + ' (' + why + ', ' + c.crashes + 'x)') + '</span>';
+  }
+
+  function renderHealth(a) {
+    const hist = (a.health && a.health.history) || [];
+    let h = '<span class="health-status">' + esc(a.health ? a.health.status : 'unknown') + '</span>';
+    for (const c of hist) {
+      const ms = Math.round(c.latency / 1e6) + 'ms';
+      const title = new Date(c.at).toLocaleTimeString() + ' ' + (c.status_code || c.error || '') + ' ' + ms;
+      h += '<span class="hc' + (c.ok ? '' : ' fail') + '" title="' + esc(title) + '"></span>';
+    }
+    return h;
+  }
+
+  function renderProcesses(procs) {
+    return procs.map(p => {
+      const title = (p.command ? p.command + '\n' : '') + 'pid ' + p.pid + ' build ' + p.build + (p.port ? ' port ' + p.port : '') +
+        (p.status === 'crashed' ? ' exit ' + p.exit_code : '');
+      return '<code class="proc ' + esc(p.status) + '" title="' + esc(title) + '">' + esc(p.name) +
+        (p.status === 'running' ? '' : ' ' + esc(p.status)) + '</code>';
+    }).join('');
+  }
+
+  function renderBuilds(space, artifacts) {
+    if (artifacts.length === 0) return '<div class="empty">No kept builds yet.</div>';
+    let h = '<table class="builds">';
+    for (const b of artifacts) {
+      const action = b.active ? '<span class="build-active">active</span>' :
+        '<button class="env-btn" onclick="rollback(\'' + esc(space) + '\', \'' + esc(b.id) + '\')">Rollback</button>';
+      h += '<tr><td><code>' + esc(b.id) + '</code></td><td><code>' + esc(b.commit || '') + '</code></td>' +
+        '<td>' + esc(new Date(b.built_at).toLocaleString()) + '</td><td>' + action + '</td></tr>';
+    }
+    return h + '</table>';
+  }
+
+  function renderLastBuild(a) {
+    const builds = a.builds || [];
+    const b = builds[builds.length - 1];
+    if (!b) return '';
+    const title = new Date(b.finished_at || b.started_at).toLocaleTimeString();
+    return '<span class="build-status ' + esc(b.status) + '" title="' + esc(title) + '">' + esc(b.status) + '</span>';
+  }
+
+  function renderLastTest(a) {
+    const runs = a.tests || [];
+    const r = runs[runs.length - 1];
+    const btn = '<button class="env-btn" onclick="runTests(\'' + esc(a.space) + '\')">Run</button> ';
+    if (!r) return btn;
+    let label = r.status;
+    if (r.status === 'pass' || r.status === 'fail') label = r.passed + ' passed, ' + r.failed + ' failed';
+    if (r.flaky) label += ', ' + r.flaky + ' flaky';
+    return btn + '<span class="logs-toggle test-status ' + esc(r.status) + '" onclick="toggleTests(\'' + esc(a.space) + '\')">' + esc(label) + '</span>';
+  }
+
+  function renderTests(a) {
+    const runs = a.tests || [];
+    const r = runs[runs.length - 1];
+    if (!r) return '<div class="empty">No test runs yet.</div>';
+    let h = '<div>go test ' + esc((r.args || []).join(' ')) + ': ' + esc(r.status) + ', ' +
+      r.passed + ' passed, ' + r.failed + ' failed, ' + r.skipped + ' skipped' + (r.flaky ? ', ' + r.flaky + ' flaky' : '') + '</div>';
+    for (const t of r.tests || []) {
+      if (t.status !== 'fail' && !t.flaky) continue;
+      let note = '';
+      if (t.flaky) note = t.status === 'pass' ? ' <span class="test-status flaky">flaky, passed on retry</span>' : ' <span class="test-status flaky">flaky</span>';
+      h += '<div><code>' + esc(t.package + (t.test ? '.' + t.test : '')) + '</code>' + note + '</div>';
+      if (t.output) h += '<pre class="logs test-output">' + esc(t.output) + '</pre>';
+    }
+    if (r.output && r.status === 'error') h += '<pre class="logs test-output">' + esc(r.output) + '</pre>';
+    return h;
+  }
+
+  window.runTests = function(space) {
+    fetch("/api/apps/" + encodeURIComponent(space) + "/tests", {
+      method: "POST",
+      headers: {"Content-Type": "application/json"},
+      body: JSON.stringify({args: []})
+    });
+  };
+
+  window.toggleTests = function(space) {
+    openTests[space] = !openTests[space];
+    render();
+  };
+
+  window.toggleBuilds = function(space) {
+    openBuilds[space] = !openBuilds[space];
+    render();
+  };
+
+  window.rollback = function(space, id) {
+    fetch("/api/apps/" + encodeURIComponent(space) + "/rollback", {
+      method: "POST",
+      headers: {"Content-Type": "application/json"},
+      body: JSON.stringify({build_id: id})
+    });
+  };
+
+  window.toggleLogs = function(space) {
+    openLogs[space] = !openLogs[space];
+    render();
+  };
 
   function render() {
     const list = Object.values(apps);
@@ -143,7 +270,7 @@ const dashboardJS = `<script>
     let h = '<table><thead><tr>' +
       '<th>Space</th><th>App</th><th>Config</th>' +
       '<th>Blue</th><th>Green</th>' +
-      '<th>Watch</th><th>Logs</th></tr></thead><tbody>';
+      '<th>Watch</th><th>Health</th><th>Processes</th><th>Builds</th><th>Tests</th><th>Logs</th></tr></thead><tbody>';
     for (const a of list) {
       const watchPats = (a.watch.match || []).slice().sort();
       const wildExts = [], other = [];
@@ -154,7 +281,11 @@ const dashboardJS = `<script>
       }
       if (wildExts.length > 1) other.unshift('*.{' + wildExts.join(',') + '}');
       else if (wildExts.length === 1) other.unshift('*.' + wildExts[0]);
-      const watch = other.map(p => '<code>' + p + '</code>').join(' ');
+      let watch = other.map(p => '<code>' + esc(p) + '</code>').join(' ');
+      const ignore = a.watch.ignore || [];
+      if (ignore.length > 0) {
+        watch += ' <span class="watch-ignore">ignore</span> ' + ignore.map(p => '<code>' + esc(p) + '</code>').join(' ');
+      }
       const parts = (a.dir || '').split('/');
       let appName = parts.pop() || '';
       if (appName === a.space && parts.length > 0) appName = parts.pop() || '';
@@ -162,13 +293,26 @@ const dashboardJS = `<script>
       const p1cls = healthy && a.ports.active === a.ports.blue ? ' class="active-port"' : '';
       const p2cls = healthy && a.ports.active === a.ports.green ? ' class="active-port"' : '';
       h += '<tr>' +
-        '<td><strong><a href="' + location.protocol + '//' + a.space + '.localhost:' + location.port + '/">' + a.space + '</a></strong></td>' +
+        '<td><strong><a href="' + location.protocol + '//' + a.space + '.localhost:' + location.port + '/">' + a.space + '</a></strong>' + renderCrash(a) + '</td>' +
         '<td><code>' + appName + '</code></td>' +
         '<td>' + (a.config || []).map(c => '<code>' + c + '</code>').join(' ') + '</td>' +
         '<td' + p1cls + '>:' + a.ports.blue + '</td>' +
         '<td' + p2cls + '>:' + a.ports.green + '</td>' +
         '<td>' + watch + '</td>' +
-        '<td>' + (a.logs || []).length + '</td></tr>';
+        '<td>' + renderHealth(a) + '</td>' +
+        '<td>' + renderProcesses(a.processes || []) + '</td>' +
+        '<td><span class="logs-toggle" onclick="toggleBuilds(\'' + a.space + '\')">' + (a.artifacts || []).length + '</span>' + renderLastBuild(a) + '</td>' +
+        '<td>' + renderLastTest(a) + '</td>' +
+        '<td><span class="logs-toggle" onclick="toggleLogs(\'' + a.space + '\')">' + (a.logs || []).length + '</span></td></tr>';
+      if (openBuilds[a.space]) {
+        h += '<tr class="logs-row"><td colspan="11">' + renderBuilds(a.space, a.artifacts || []) + '</td></tr>';
+      }
+      if (openTests[a.space]) {
+        h += '<tr class="logs-row"><td colspan="11">' + renderTests(a) + '</td></tr>';
+      }
+      if (openLogs[a.space]) {
+        h += '<tr class="logs-row"><td colspan="11">' + renderLogs(a.logs || []) + '</td></tr>';
+      }
     }
     h += '</tbody></table>';
     table.innerHTML = h;
@@ -189,6 +333,23 @@ const dashboardJS = `<script>
     render();
   });
 
+  // Logs and probes come as small events with only what changed.
+  es.addEventListener("log", function(e) {
+    const data = JSON.parse(e.data);
+    const a = apps[data.space];
+    if (!a) return;
+    a.logs = (a.logs || []).concat(data.logs).slice(-maxLogs);
+    render();
+  });
+
+  es.addEventListener("health", function(e) {
+    const data = JSON.parse(e.data);
+    const a = apps[data.space];
+    if (!a) return;
+    a.health = data.health;
+    render();
+  });
+
   es.addEventListener("deregister", function(e) {
     const data = JSON.parse(e.data);
     delete apps[data.space];
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const drainPoll = 50 * time.Millisecond
+
+// sseRetryHint ends a proxied event stream so EventSource reconnects right
+// away, which lands it on the new port. The leading blank lines finish any
+// event already in flight.
+var sseRetryHint = []byte("\n\nretry: 100\n\n")
+
+// upstream tracks proxied requests to one app port. drain is closed when the
+// port is being retired so event streams can let go, and retired when its
+// grace period is over so upgraded connections are cut.
+type upstream struct {
+	active  int
+	drain   chan struct{}
+	retired chan struct{}
+}
+
+// track counts a request to port until release is called. Requests that
+// arrive while the port drains get its upstream, already draining.
+func (s *Server) track(port int) (u *upstream, release func()) {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	u = s.upstreamLocked(port)
+	u.active++
+	return u, func() {
+		s.connMu.Lock()
+		defer s.connMu.Unlock()
+		u.active--
+	}
+}
+
+func (s *Server) upstreamLocked(port int) *upstream {
+	u, ok := s.upstreams[port]
+	if !ok {
+		u = &upstream{drain: make(chan struct{}), retired: make(chan struct{})}
+		s.upstreams[port] = u
+	}
+	return u
+}
+
+// drain hints streams on port to reconnect and waits up to grace for the
+// remaining requests to finish. It returns how many are still open. The
+// port is forgotten once the grace period is over.
+func (s *Server) drain(port int, grace time.Duration) int {
+	s.connMu.Lock()
+	u := s.upstreamLocked(port)
+	closeOnce(u.drain)
+	s.connMu.Unlock()
+
+	deadline := time.Now().Add(grace)
+	for {
+		s.connMu.Lock()
+		n := u.active
+		s.connMu.Unlock()
+		if n == 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(drainPoll)
+	}
+
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	closeOnce(u.retired)
+	if s.upstreams[port] == u {
+		delete(s.upstreams, port)
+	}
+	return u.active
+}
+
+// closeOnce closes ch unless it is closed. Callers hold connMu.
+func closeOnce(ch chan struct{}) {
+	select {
+	case <-ch:
+	default:
+		close(ch)
+	}
+}
+
+// drainRequest cancels upgraded connections like websockets once the port's
+// grace period is over. The reverse proxy closes the backend conn when the
+// context ends.
+func drainRequest(req *http.Request, retired <-chan struct{}) (*http.Request, func()) {
+	if !strings.EqualFold(req.Header.Get("Connection"), "upgrade") && req.Header.Get("Upgrade") == "" {
+		return req, func() {}
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	go func() {
+		select {
+		case <-retired:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return req.WithContext(ctx), cancel
+}
+
+// drainBody wraps an event stream body. Once the port drains it closes the
+// upstream body and sends sseRetryHint before EOF.
+type drainBody struct {
+	io.ReadCloser
+	closed chan struct{}
+	drain  <-chan struct{}
+	hint   []byte
+}
+
+func newDrainBody(rc io.ReadCloser, drain <-chan struct{}) *drainBody {
+	b := &drainBody{
+		ReadCloser: rc,
+		closed:     make(chan struct{}),
+		drain:      drain,
+		hint:       sseRetryHint,
+	}
+	go func() {
+		select {
+		case <-drain:
+			rc.Close()
+		case <-b.closed:
+		}
+	}()
+	return b
+}
+
+func (b *drainBody) Read(p []byte) (int, error) {
+	select {
+	case <-b.drain:
+		if len(b.hint) == 0 {
+			return 0, io.EOF
+		}
+		n := copy(p, b.hint)
+		b.hint = b.hint[n:]
+		return n, nil
+	default:
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && n == 0 {
+		select {
+		case <-b.drain:
+			return b.Read(p)
+		default:
+		}
+	}
+	return n, err
+}
+
+func (b *drainBody) Close() error {
+	select {
+	case <-b.closed:
+	default:
+		close(b.closed)
+	}
+	return b.ReadCloser.Close()
+}
+
+func (s *Server) handleDrainPost(c echo.Context) error {
+	space := c.Param("space")
+	if _, ok := s.get(space); !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+	var body struct {
+		Grace time.Duration `json:"grace"`
+		Port  int           `json:"port"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	start := time.Now()
+	remaining := s.drain(body.Port, body.Grace)
+	s.logger.Info("drain", "space", space, "port", body.Port, "remaining", remaining, "took", time.Since(start).Round(time.Millisecond))
+	return c.JSON(http.StatusOK, map[string]int{"remaining": remaining})
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const maxHealthHistory = 20
+
+type HealthConfig struct {
+	ExpectStatus int
+	Interval     time.Duration
+	Path         string
+	Threshold    int
+	Timeout      time.Duration
+}
+
+func DefaultHealthConfig() HealthConfig {
+	return HealthConfig{
+		ExpectStatus: http.StatusOK,
+		Interval:     5 * time.Second,
+		Path:         "/health",
+		Threshold:    3,
+		Timeout:      time.Second,
+	}
+}
+
+// MonitorHealth probes the active port of every app on an interval so apps
+// that wedge after a healthy swap turn unhealthy.
+func (s *Server) MonitorHealth() {
+	ticker := time.NewTicker(s.config.Health.Interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.probeHealth()
+	}
+}
+
+func (s *Server) probeHealth() {
+	type target struct {
+		port  int
+		probe Probe
+		space string
+	}
+
+	s.mu.RLock()
+	targets := make([]target, 0, len(s.apps))
+	for _, app := range s.apps {
+		targets = append(targets, target{port: app.Ports.Active, probe: app.Probe, space: app.Space})
+	}
+	s.mu.RUnlock()
+
+	for _, t := range targets {
+		check := s.checkHealth(t.port, t.probe)
+		if health, ok := s.recordHealth(t.space, t.port, check); ok {
+			s.broadcast("health", HealthEvent{Health: health, Space: t.space})
+		}
+	}
+}
+
+func (s *Server) checkHealth(port int, probe Probe) HealthCheck {
+	cfg := s.config.Health
+	if probe.Path != "" {
+		cfg.Path = probe.Path
+	}
+	if probe.Timeout > 0 {
+		cfg.Timeout = probe.Timeout
+	}
+	c := &http.Client{Timeout: cfg.Timeout}
+
+	check := HealthCheck{At: time.Now()}
+	resp, err := c.Get(fmt.Sprintf("http://localhost:%d%s", port, cfg.Path))
+	check.Latency = time.Since(check.At)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	resp.Body.Close()
+
+	check.StatusCode = resp.StatusCode
+	check.OK = resp.StatusCode == cfg.ExpectStatus
+	return check
+}
+
+// recordHealth adds check to the app's history and flips its status after
+// a success or after Threshold failures in a row. Checks against a port that
+// has since been swapped out are dropped.
+func (s *Server) recordHealth(space string, port int, check HealthCheck) (Health, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app, ok := s.apps[space]
+	if !ok || app.Ports.Active != port {
+		return Health{}, false
+	}
+
+	app.Health.History = append(app.Health.History, check)
+	if len(app.Health.History) > maxHealthHistory {
+		app.Health.History = app.Health.History[len(app.Health.History)-maxHealthHistory:]
+	}
+
+	if check.OK {
+		s.healthFails[space] = 0
+		if app.Health.Status != "healthy" {
+			s.logger.Info("probe", "space", space, "status", "healthy", "port", port)
+			app.Health.Crash = nil
+			app.Health.Status = "healthy"
+			app.Health.UpdatedAt = check.At
+		}
+		if s.lastRegistered == "" {
+			s.lastRegistered = space
+		}
+		return app.Health.clone(), true
+	}
+
+	s.healthFails[space]++
+	if s.healthFails[space] >= s.config.Health.Threshold && app.Health.Status == "healthy" {
+		s.logger.Warn("probe", "space", space, "status", "unhealthy", "port", port, "failures", s.healthFails[space], "error", check.Error, "code", check.StatusCode)
+		app.Health.Status = "unhealthy"
+		app.Health.UpdatedAt = check.At
+	}
+	return app.Health.clone(), true
+}
+
+// clone copies h so it can be sent after the lock is released.
+func (h Health) clone() Health {
+	h.History = slices.Clone(h.History)
+	return h
+}
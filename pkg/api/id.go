@@ -0,0 +1,17 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// NewID is an ID for a build or test run started at t: the time in base 36,
+// so IDs sort by start, and a random suffix so runs started in the same
+// millisecond, even by different processes, don't collide.
+func NewID(t time.Time) string {
+	b := make([]byte, 3)
+	rand.Read(b)
+	return strconv.FormatInt(t.UnixMilli(), 36) + hex.EncodeToString(b)
+}
@@ -0,0 +1,18 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewID(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Now()
+	first, second := NewID(now), NewID(now)
+	a.NotEqual(first, second)
+	a.Equal(first[:len(first)-6], second[:len(second)-6])
+	a.Less(first, NewID(now.Add(time.Millisecond)))
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+)
+
+// LocalStatus checks that r is from a local client: over loopback, with a
+// JSON body and no Origin but a loopback one, which keeps web pages from
+// posting cross-origin forms. It returns the status to refuse r with, or 0.
+func LocalStatus(r *http.Request) int {
+	if !loopback(r.RemoteAddr) || !localOrigin(r.Header.Get("Origin")) {
+		return http.StatusForbidden
+	}
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+		return http.StatusUnsupportedMediaType
+	}
+	return 0
+}
+
+// localOnly guards routes that can set a command to run, like callbacks.
+func localOnly(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if code := LocalStatus(c.Request()); code != 0 {
+			return c.JSON(code, map[string]string{"error": http.StatusText(code)})
+		}
+		return next(c)
+	}
+}
+
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// localOrigin allows requests without an Origin, as from agents and the
+// runner, and browser requests from localhost itself but not from app
+// spaces.
+func localOrigin(origin string) bool {
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return u.Hostname() == "localhost" || loopback(u.Hostname())
+}
@@ -2,10 +2,15 @@ package api
 
 import (
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
@@ -180,3 +185,384 @@ func TestOAuthStateConsumedAfterUse(t *testing.T) {
 	a.Equal(http.StatusTemporaryRedirect, rec2.Code)
 	a.Contains(rec2.Header().Get("Location"), "manama.localhost")
 }
+
+func TestProxyServesBuildError(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer upstream.Close()
+	upstreamPort := upstream.Listener.Addr().(*net.TCPAddr).Port
+
+	tests := []struct {
+		_name  string
+		body   string
+		health string
+		out    int
+		status string
+	}{
+		{
+			_name:  "failed build and no healthy port",
+			body:   "undefined: foo",
+			health: "unknown",
+			out:    http.StatusServiceUnavailable,
+			status: "failed",
+		},
+		{
+			_name:  "failed build with healthy port proxies",
+			body:   "ok",
+			health: "healthy",
+			out:    http.StatusOK,
+			status: "failed",
+		},
+		{
+			_name:  "successful build proxies",
+			body:   "ok",
+			health: "unknown",
+			out:    http.StatusOK,
+			status: "success",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+
+			srv := NewServer(ServerConfig{
+				BluePortStart: upstreamPort,
+				DashboardPort: 50000,
+				PostgresPort:  54320,
+			}, slog.Default())
+			srv.register(AppIn{Space: "buffalo", Dir: t.TempDir()})
+			srv.updateHealth("buffalo", tt.health, 0)
+			srv.appendBuild("buffalo", BuildResult{
+				Errors: []BuildError{{File: "main.go", Line: 3, Column: 1, Message: "undefined: foo", Tool: "go"}},
+				Status: tt.status,
+				Step:   "build",
+			})
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = "buffalo.localhost:50000"
+			rec := httptest.NewRecorder()
+
+			a.NoError(srv.handleProxy(e.NewContext(req, rec)))
+			a.Equal(tt.out, rec.Code)
+			a.Contains(rec.Body.String(), tt.body)
+		})
+	}
+}
+
+func TestProbeHealth(t *testing.T) {
+	var code atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(code.Load()))
+	}))
+	defer upstream.Close()
+	upstreamPort := upstream.Listener.Addr().(*net.TCPAddr).Port
+
+	tests := []struct {
+		_name   string
+		codes   []int
+		history int
+		out     string
+		status  string
+	}{
+		{
+			_name:   "unknown turns healthy",
+			codes:   []int{http.StatusOK},
+			history: 1,
+			out:     "healthy",
+			status:  "unknown",
+		},
+		{
+			_name:   "failures below threshold stay healthy",
+			codes:   []int{http.StatusInternalServerError, http.StatusInternalServerError},
+			history: 2,
+			out:     "healthy",
+			status:  "healthy",
+		},
+		{
+			_name:   "failures at threshold turn unhealthy",
+			codes:   []int{http.StatusInternalServerError, http.StatusOK, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			history: 5,
+			out:     "unhealthy",
+			status:  "healthy",
+		},
+		{
+			_name:   "crashed is left alone",
+			codes:   []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			history: 3,
+			out:     "crashed",
+			status:  "crashed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+
+			cfg := DefaultHealthConfig()
+			srv := NewServer(ServerConfig{
+				BluePortStart: upstreamPort,
+				DashboardPort: 50000,
+				Health:        cfg,
+				PostgresPort:  54320,
+			}, slog.Default())
+			srv.register(AppIn{Space: "buffalo", Dir: t.TempDir()})
+			srv.updateHealth("buffalo", tt.status, 0)
+
+			for _, c := range tt.codes {
+				code.Store(int32(c))
+				srv.probeHealth()
+			}
+
+			app, _ := srv.get("buffalo")
+			a.Equal(tt.out, app.Health.Status)
+			a.Len(app.Health.History, tt.history)
+		})
+	}
+}
+
+func TestSmallEvents(t *testing.T) {
+	a := assert.New(t)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	srv := NewServer(ServerConfig{
+		BluePortStart: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		DashboardPort: 50000,
+		Health:        DefaultHealthConfig(),
+		PostgresPort:  54320,
+	}, slog.Default())
+	srv.register(AppIn{Space: "buffalo", Dir: t.TempDir()})
+	srv.appendBuild("buffalo", BuildResult{Output: strings.Repeat("x", 1000), Status: "failed"})
+	events := make(chan []byte, 16)
+	srv.subscribers[events] = struct{}{}
+
+	e := echo.New()
+	srv.Routes(e)
+	req := httptest.NewRequest(http.MethodPost, "/api/apps/buffalo/logs", strings.NewReader(`[{"level":"info","message":"hello","timestamp":"2026-01-02T03:04:05Z"}]`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	a.Equal(http.StatusNoContent, rec.Code)
+	a.Equal("event: log\ndata: {\"logs\":[{\"level\":\"info\",\"message\":\"hello\",\"timestamp\":\"2026-01-02T03:04:05Z\"}],\"space\":\"buffalo\"}\n\n", string(<-events))
+
+	srv.probeHealth()
+	msg := string(<-events)
+	a.True(strings.HasPrefix(msg, "event: health\ndata: {\"health\":{"))
+	a.NotContains(msg, "xxx")
+}
+
+func TestDrain(t *testing.T) {
+	a := assert.New(t)
+
+	slow := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-slow
+			fmt.Fprint(w, "done")
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: hello\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer upstream.Close()
+	upstreamPort := upstream.Listener.Addr().(*net.TCPAddr).Port
+
+	srv := NewServer(ServerConfig{BluePortStart: upstreamPort, DashboardPort: 50000, PostgresPort: 54320}, slog.Default())
+	srv.register(AppIn{Space: "buffalo", Dir: t.TempDir()})
+	e := echo.New()
+	e.Any("/*", srv.handleProxy)
+	proxy := httptest.NewServer(e)
+	defer proxy.Close()
+
+	get := func(path string) (*http.Response, error) {
+		req, _ := http.NewRequest(http.MethodGet, proxy.URL+path, nil)
+		req.Host = "buffalo.localhost:50000"
+		return http.DefaultClient.Do(req)
+	}
+
+	stream, err := get("/events")
+	a.NoError(err)
+	defer stream.Body.Close()
+	first := make([]byte, len("data: hello\n\n"))
+	_, err = io.ReadFull(stream.Body, first)
+	a.NoError(err)
+
+	slowDone := make(chan string)
+	go func() {
+		resp, err := get("/slow")
+		if err != nil {
+			slowDone <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		slowDone <- string(body)
+	}()
+	a.Eventually(func() bool {
+		srv.connMu.Lock()
+		defer srv.connMu.Unlock()
+		return srv.upstreams[upstreamPort] != nil && srv.upstreams[upstreamPort].active == 2
+	}, time.Second, 10*time.Millisecond)
+
+	remaining := make(chan int)
+	go func() { remaining <- srv.drain(upstreamPort, 5*time.Second) }()
+
+	rest, _ := io.ReadAll(stream.Body)
+	a.Contains(string(rest), "retry: 100")
+
+	close(slow)
+	a.Equal("done", <-slowDone)
+	a.Equal(0, <-remaining)
+	srv.connMu.Lock()
+	a.Nil(srv.upstreams[upstreamPort])
+	srv.connMu.Unlock()
+}
+
+func TestDrainLateRequest(t *testing.T) {
+	a := assert.New(t)
+
+	srv := NewServer(ServerConfig{BluePortStart: 4000, DashboardPort: 50000, PostgresPort: 54320}, slog.Default())
+	first, release := srv.track(4000)
+	defer release()
+
+	remaining := make(chan int)
+	go func() { remaining <- srv.drain(4000, 100*time.Millisecond) }()
+	a.Eventually(func() bool {
+		select {
+		case <-first.drain:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+
+	late, lateRelease := srv.track(4000)
+	defer lateRelease()
+	a.Same(first, late)
+	select {
+	case <-late.retired:
+		a.Fail("retired before the grace period")
+	default:
+	}
+
+	a.Equal(2, <-remaining)
+	_, open := <-late.retired
+	a.False(open)
+	srv.connMu.Lock()
+	a.Nil(srv.upstreams[4000])
+	srv.connMu.Unlock()
+}
+
+func TestRollback(t *testing.T) {
+	tests := []struct {
+		_name   string
+		buildID string
+		out     int
+		space   string
+	}{
+		{_name: "previous build", space: "buffalo", out: http.StatusAccepted},
+		{_name: "kept build", buildID: "m1a2b3", space: "buffalo", out: http.StatusAccepted},
+		{_name: "unknown build", buildID: "nope", space: "buffalo", out: http.StatusNotFound},
+		{_name: "unknown space", space: "manama", out: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+
+			srv := NewServer(ServerConfig{BluePortStart: 4000, DashboardPort: 50000, PostgresPort: 54320}, slog.Default())
+			srv.register(AppIn{Space: "buffalo", Dir: t.TempDir()})
+			srv.setArtifacts("buffalo", []Artifact{{Active: true, ID: "m1a2b4"}, {ID: "m1a2b3"}})
+			events := make(chan []byte, 1)
+			srv.subscribers[events] = struct{}{}
+
+			e := echo.New()
+			srv.Routes(e)
+			req := httptest.NewRequest(http.MethodPost, "/api/apps/"+tt.space+"/rollback", strings.NewReader(`{"build_id":"`+tt.buildID+`"}`))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			a.Equal(tt.out, rec.Code)
+			if tt.out == http.StatusAccepted {
+				event := string(<-events)
+				a.Contains(event, `"action":"rollback"`)
+				a.Contains(event, tt.buildID)
+			}
+		})
+	}
+}
+
+func TestAssets(t *testing.T) {
+	tests := []struct {
+		_name string
+		out   int
+		space string
+	}{
+		{_name: "registered space", space: "buffalo", out: http.StatusAccepted},
+		{_name: "unknown space", space: "manama", out: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+
+			srv := NewServer(ServerConfig{BluePortStart: 4000, DashboardPort: 50000, PostgresPort: 54320}, slog.Default())
+			srv.register(AppIn{Space: "buffalo", Dir: t.TempDir()})
+			events := make(chan []byte, 1)
+			srv.subscribers[events] = struct{}{}
+
+			e := echo.New()
+			srv.Routes(e)
+			req := httptest.NewRequest(http.MethodPost, "/api/apps/"+tt.space+"/assets", strings.NewReader(`{"paths":["static/app.css"]}`))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			a.Equal(tt.out, rec.Code)
+			if tt.out == http.StatusAccepted {
+				a.Equal("event: asset\ndata: {\"paths\":[\"static/app.css\"],\"space\":\"buffalo\"}\n\n", string(<-events))
+			}
+		})
+	}
+}
+
+func TestTests(t *testing.T) {
+	a := assert.New(t)
+
+	srv := NewServer(ServerConfig{BluePortStart: 4000, DashboardPort: 50000, PostgresPort: 54320}, slog.Default())
+	srv.register(AppIn{Space: "buffalo", Dir: t.TempDir()})
+	events := make(chan []byte, 4)
+	srv.subscribers[events] = struct{}{}
+	e := echo.New()
+	srv.Routes(e)
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+	client := NewClient(ts.URL)
+
+	run, err := client.TestStart("buffalo", []string{"-short"})
+	a.NoError(err)
+	a.Equal("queued", run.Status)
+	a.Contains(string(<-events), `"action":"test","args":["-short"],"space":"buffalo","test_id":"`+run.ID+`"`)
+
+	first := TestRun{ID: run.ID, Passed: 1, Failed: 1, Status: "fail", Tests: []TestResult{
+		{Package: "example.com/greet", Status: "pass", Test: "TestOK"},
+		{Package: "example.com/greet", Status: "fail", Test: "TestBoom"},
+	}}
+	client.TestPut("buffalo", first)
+	got, err := client.TestGet("buffalo", run.ID)
+	a.NoError(err)
+	a.Equal(first.Tests, got.Tests)
+
+	client.TestPut("buffalo", TestRun{ID: "later", Status: "running"})
+	runs, _ := srv.tests("buffalo")
+	a.Len(runs, 2)
+	a.Equal([]TestResult{first.Tests[1]}, runs[0].Tests)
+
+	_, err = client.TestGet("buffalo", "nope")
+	a.Error(err)
+	_, err = client.TestStart("manama", nil)
+	a.Error(err)
+}
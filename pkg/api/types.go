@@ -2,21 +2,73 @@ package api
 
 import "time"
 
+const (
+	ActionRebuild  = "rebuild"
+	ActionReset    = "reset"
+	ActionRestart  = "restart"
+	ActionRollback = "rollback"
+	ActionTest     = "test"
+)
+
+type Action struct {
+	Action  string   `json:"action"`
+	Args    []string `json:"args,omitempty"`
+	BuildID string   `json:"build_id,omitempty"`
+	Space   string   `json:"space"`
+	TestID  string   `json:"test_id,omitempty"`
+}
+
+// Assets lists static files, relative to the app dir, that changed without
+// needing a rebuild.
+type Assets struct {
+	Paths []string `json:"paths"`
+	Space string   `json:"space"`
+}
+
 type App struct {
-	Config      []string  `json:"config"`
-	CreatedAt   time.Time `json:"created_at"`
-	DatabaseURL string    `json:"database_url"`
-	Dir         string    `json:"dir"`
-	Health      Health    `json:"health"`
-	Logs        []Log     `json:"logs"`
-	Ports       Ports     `json:"ports"`
-	Space       string    `json:"space"`
-	Watch       Watch     `json:"watch"`
+	Artifacts   []Artifact    `json:"artifacts"`
+	Builds      []BuildResult `json:"builds"`
+	Config      []string      `json:"config"`
+	CreatedAt   time.Time     `json:"created_at"`
+	DatabaseURL string        `json:"database_url"`
+	Dir         string        `json:"dir"`
+	Health      Health        `json:"health"`
+	Logs        []Log         `json:"logs"`
+	Ports       Ports         `json:"ports"`
+	Probe       Probe         `json:"probe"`
+	Processes   []Process     `json:"processes"`
+	Space       string        `json:"space"`
+	Tests       []TestRun     `json:"tests"`
+	Watch       Watch         `json:"watch"`
 }
 
 type Health struct {
-	Status    string    `json:"status"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Crash     *Crash        `json:"crash,omitempty"`
+	History   []HealthCheck `json:"history,omitempty"`
+	Status    string        `json:"status"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// HealthEvent is the "health" event: a space's health after a probe.
+type HealthEvent struct {
+	Health Health `json:"health"`
+	Space  string `json:"space"`
+}
+
+type HealthCheck struct {
+	At         time.Time     `json:"at"`
+	Error      string        `json:"error,omitempty"`
+	Latency    time.Duration `json:"latency"`
+	OK         bool          `json:"ok"`
+	StatusCode int           `json:"status_code,omitempty"`
+}
+
+type Crash struct {
+	At       time.Time `json:"at"`
+	Crashes  int       `json:"crashes"`
+	ExitCode int       `json:"exit_code"`
+	Output   []string  `json:"output"`
+	Signal   string    `json:"signal,omitempty"`
 }
 
 type Ports struct {
@@ -30,10 +82,108 @@ type Watch struct {
 	Match  []string `json:"match"`
 }
 
+// Artifact is a successful build the runner kept around for rollback.
+type Artifact struct {
+	Active  bool      `json:"active"`
+	BuiltAt time.Time `json:"built_at"`
+	Commit  string    `json:"commit,omitempty"`
+	ID      string    `json:"id"`
+}
+
+// Process is a binary the runner has running in a space: the web process
+// on a port or a worker with no port.
+type Process struct {
+	Build     string    `json:"build"`
+	Command   string    `json:"command,omitempty"`
+	ExitCode  int       `json:"exit_code,omitempty"`
+	Name      string    `json:"name"`
+	Pid       int       `json:"pid"`
+	Port      int       `json:"port,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	Status    string    `json:"status"`
+}
+
+type BuildResult struct {
+	Commit     string       `json:"commit,omitempty"`
+	Errors     []BuildError `json:"errors"`
+	FinishedAt time.Time    `json:"finished_at"`
+	ID         string       `json:"id"`
+	Output     string       `json:"output,omitempty"`
+	StartedAt  time.Time    `json:"started_at"`
+	Status     string       `json:"status"`
+	Step       string       `json:"step,omitempty"`
+}
+
+// TestRun is a go test run in a space. Status is "queued" until the runner
+// picks it up, "running", then "pass", "fail" or "error", or "canceled"
+// when newer changes replaced a watch run.
+type TestRun struct {
+	Args       []string     `json:"args,omitempty"`
+	Failed     int          `json:"failed"`
+	FinishedAt time.Time    `json:"finished_at"`
+	Flaky      int          `json:"flaky,omitempty"`
+	ID         string       `json:"id"`
+	Output     string       `json:"output,omitempty"`
+	Passed     int          `json:"passed"`
+	Skipped    int          `json:"skipped"`
+	StartedAt  time.Time    `json:"started_at"`
+	Status     string       `json:"status"`
+	Tests      []TestResult `json:"tests"`
+}
+
+// TestResult is one test, or a package that failed without a failing test.
+// Output is kept for failures and skips. Flaky tests passed on a retry or
+// have passed and failed before at the same commit.
+type TestResult struct {
+	Elapsed time.Duration `json:"elapsed"`
+	Flaky   bool          `json:"flaky,omitempty"`
+	Output  string        `json:"output,omitempty"`
+	Package string        `json:"package"`
+	Status  string        `json:"status"`
+	Test    string        `json:"test,omitempty"`
+}
+
+type BuildError struct {
+	Column  int    `json:"column,omitempty"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+	Tool    string `json:"tool"`
+}
+
+type Callback struct {
+	Command string `json:"command,omitempty"`
+	URL     string `json:"url,omitempty"`
+}
+
+type CallbackEvent struct {
+	Build     *BuildResult `json:"build,omitempty"`
+	Count     int          `json:"count"`
+	Crash     *Crash       `json:"crash,omitempty"`
+	Kind      string       `json:"kind"`
+	Log       *Log         `json:"log,omitempty"`
+	Message   string       `json:"message"`
+	Test      *TestRun     `json:"test,omitempty"`
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+type CallbackPayload struct {
+	Dir    string          `json:"dir"`
+	Events []CallbackEvent `json:"events"`
+	Space  string          `json:"space"`
+}
+
 type Log struct {
-	Level     string    `json:"level"`
-	Message   string    `json:"message"`
-	Timestamp time.Time `json:"timestamp"`
+	Attrs     map[string]any `json:"attrs,omitempty"`
+	Level     string         `json:"level"`
+	Message   string         `json:"message"`
+	Timestamp time.Time      `json:"timestamp"`
+}
+
+// LogEvent is the "log" event: the lines a space just logged.
+type LogEvent struct {
+	Logs  []Log  `json:"logs"`
+	Space string `json:"space"`
 }
 
 type Status struct {
@@ -46,10 +196,22 @@ type Status struct {
 }
 
 type AppIn struct {
-	Config []string `json:"config"`
-	Dir    string   `json:"dir"`
-	Space  string   `json:"space"`
-	Watch  Watch    `json:"watch"`
+	Callback *Callback `json:"callback,omitempty"`
+	Config   []string  `json:"config"`
+	Dir      string    `json:"dir"`
+	Probe    Probe     `json:"probe"`
+	Space    string    `json:"space"`
+	Watch    Watch     `json:"watch"`
+}
+
+// Probe configures how an app is checked. Path is polled for liveness and
+// ReadyPath, falling back to Path, gates a swap. Zero values use defaults.
+type Probe struct {
+	Interval  time.Duration `json:"interval,omitempty"`
+	Path      string        `json:"path,omitempty"`
+	ReadyPath string        `json:"ready_path,omitempty"`
+	Retries   int           `json:"retries,omitempty"`
+	Timeout   time.Duration `json:"timeout,omitempty"`
 }
 
 type AppOut struct {
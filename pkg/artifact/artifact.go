@@ -0,0 +1,149 @@
+package artifact
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// minAge protects build dirs that may still be in use by a build that has
+// not reported yet.
+const minAge = 10 * time.Minute
+
+// tempPattern matches the temp dirs older versions built into.
+const tempPattern = "cheetah-build-*"
+
+// Store keeps build binaries under Root/<space>/<id>.
+type Store struct {
+	Root string
+	Temp string
+}
+
+type Reclaimed struct {
+	Bytes int64    `json:"bytes"`
+	Paths []string `json:"paths"`
+}
+
+func DefaultStore() Store {
+	home, _ := os.UserHomeDir()
+	return Store{
+		Root: filepath.Join(home, ".cheetah", "builds"),
+		Temp: os.TempDir(),
+	}
+}
+
+func (s Store) Dir(space, id string) string {
+	return filepath.Join(s.Root, space, id)
+}
+
+// Create makes the dir for a new build.
+func (s Store) Create(space, id string) (string, error) {
+	if s.Root == "" {
+		return "", errors.New("artifact store has no root")
+	}
+	dir := s.Dir(space, id)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", errors.Wrap(err, "create build dir")
+	}
+	return dir, nil
+}
+
+func (s Store) Delete(space, id string) (Reclaimed, error) {
+	var r Reclaimed
+	if s.Root == "" || space == "" || id == "" {
+		return r, nil
+	}
+	err := r.remove(s.Dir(space, id))
+	return r, err
+}
+
+func (s Store) DeleteSpace(space string) (Reclaimed, error) {
+	var r Reclaimed
+	if s.Root == "" || space == "" {
+		return r, nil
+	}
+	err := r.remove(filepath.Join(s.Root, space))
+	return r, err
+}
+
+// GC removes builds that no running space still keeps: dirs of spaces not in
+// live, builds of live spaces not in their kept list, and leftover temp dirs.
+// Anything newer than minAge is left alone.
+func (s Store) GC(live map[string][]string) (Reclaimed, error) {
+	var r Reclaimed
+	cutoff := time.Now().Add(-minAge)
+
+	if s.Root != "" {
+		spaces, err := os.ReadDir(s.Root)
+		if err != nil && !os.IsNotExist(err) {
+			return r, errors.Wrap(err, "read builds")
+		}
+		for _, space := range spaces {
+			dir := filepath.Join(s.Root, space.Name())
+			kept, ok := live[space.Name()]
+			if !ok {
+				if err := r.removeOlder(dir, cutoff); err != nil {
+					return r, err
+				}
+				continue
+			}
+			builds, err := os.ReadDir(dir)
+			if err != nil {
+				return r, errors.Wrap(err, "read space builds")
+			}
+			for _, b := range builds {
+				if slices.Contains(kept, b.Name()) {
+					continue
+				}
+				if err := r.removeOlder(filepath.Join(dir, b.Name()), cutoff); err != nil {
+					return r, err
+				}
+			}
+		}
+	}
+
+	if s.Temp != "" {
+		dirs, _ := filepath.Glob(filepath.Join(s.Temp, tempPattern))
+		for _, dir := range dirs {
+			if err := r.removeOlder(dir, cutoff); err != nil {
+				return r, err
+			}
+		}
+	}
+
+	return r, nil
+}
+
+func (r *Reclaimed) removeOlder(path string, cutoff time.Time) error {
+	info, err := os.Stat(path)
+	if err != nil || info.ModTime().After(cutoff) {
+		return nil
+	}
+	return r.remove(path)
+}
+
+func (r *Reclaimed) remove(path string) error {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if info, err := d.Info(); err == nil && !d.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return errors.Wrap(err, "remove")
+	}
+	r.Bytes += size
+	r.Paths = append(r.Paths, path)
+	return nil
+}
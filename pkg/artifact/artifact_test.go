@@ -0,0 +1,100 @@
+package artifact
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGC(t *testing.T) {
+	tests := []struct {
+		_name string
+		dirs  map[string]time.Duration
+		live  map[string][]string
+		out   []string
+	}{
+		{
+			_name: "removes builds no live space keeps",
+			dirs: map[string]time.Duration{
+				"builds/buffalo/a": time.Hour,
+				"builds/buffalo/b": time.Hour,
+			},
+			live: map[string][]string{"buffalo": {"b"}},
+			out:  []string{"builds/buffalo/a"},
+		},
+		{
+			_name: "removes dead spaces",
+			dirs: map[string]time.Duration{
+				"builds/manama/a": time.Hour,
+			},
+			live: map[string][]string{},
+			out:  []string{"builds/manama"},
+		},
+		{
+			_name: "keeps recent builds",
+			dirs: map[string]time.Duration{
+				"builds/buffalo/a": time.Minute,
+			},
+			live: map[string][]string{"buffalo": {}},
+		},
+		{
+			_name: "removes stale temp dirs",
+			dirs: map[string]time.Duration{
+				"tmp/cheetah-build-123": time.Hour,
+				"tmp/cheetah-build-456": time.Minute,
+				"tmp/other":             time.Hour,
+			},
+			out: []string{"tmp/cheetah-build-123"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+			root := t.TempDir()
+			s := Store{Root: filepath.Join(root, "builds"), Temp: filepath.Join(root, "tmp")}
+
+			for dir, age := range tt.dirs {
+				path := filepath.Join(root, dir)
+				os.MkdirAll(path, 0o755)
+				os.WriteFile(filepath.Join(path, "app"), []byte("bin"), 0o755)
+				old := time.Now().Add(-age)
+				for p := path; p != root; p = filepath.Dir(p) {
+					os.Chtimes(p, old, old)
+				}
+			}
+
+			rec, err := s.GC(tt.live)
+			a.NoError(err)
+
+			var out []string
+			for _, p := range rec.Paths {
+				rel, _ := filepath.Rel(root, p)
+				out = append(out, rel)
+				a.NoDirExists(p)
+			}
+			a.Equal(tt.out, out)
+			a.Equal(int64(3*len(tt.out)), rec.Bytes)
+		})
+	}
+}
+
+func TestDeleteSpace(t *testing.T) {
+	a := assert.New(t)
+	s := Store{Root: t.TempDir()}
+
+	dir, err := s.Create("buffalo", "a")
+	a.NoError(err)
+	os.WriteFile(filepath.Join(dir, "app"), []byte("bin"), 0o755)
+
+	rec, err := s.DeleteSpace("buffalo")
+	a.NoError(err)
+	a.Equal(int64(3), rec.Bytes)
+	a.NoDirExists(filepath.Join(s.Root, "buffalo"))
+
+	rec, err = Store{}.DeleteSpace("buffalo")
+	a.NoError(err)
+	a.Empty(rec.Paths)
+}
@@ -1,77 +1,324 @@
 package build
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/cockroachdb/errors"
+
+	"github.com/housecat-inc/cheetah/pkg/api"
+	"github.com/housecat-inc/cheetah/pkg/artifact"
+)
+
+const maxOutput = 16 << 10
+
+var (
+	diagRe    = regexp.MustCompile(`^(?:\./)?([^\s:]+\.(?:go|templ|sql|ya?ml)):(\d+)(?::(\d+))?:\s*(.+)$`)
+	runningRe = regexp.MustCompile(`running "([^"]+)"`)
+	templRe   = regexp.MustCompile(`(\S+\.templ) parsing error: (.*): line (\d+), col (\d+)`)
 )
 
+// In configures a build and the process it starts. Generate runs before go
+// build; nil means GoGenerate and empty runs nothing. Nil Targets means
+// DefaultTargets.
 type In struct {
 	AppEnv              map[string]string
 	CheetahURL          string
 	DatabaseTemplateURL string
 	DatabaseURL         string
+	Generate            []Generator
+	Output              io.Writer
 	Port                int
 	Space               string
+	Store               artifact.Store
+	Targets             []Target
 }
 
+// Out has the binary of the first target, which serves PORT, and the
+// binaries of the rest by target name.
 type Out struct {
-	Cmd *exec.Cmd
+	Binary  string
+	Result  api.BuildResult
+	Workers map[string]string
+}
+
+// Generator is a code generation command. Step names it in failed build
+// results.
+type Generator struct {
+	Args []string
+	Step string
+}
+
+var GoGenerate = Generator{Args: []string{"go", "generate", "./..."}, Step: "generate"}
+
+// Sqlc regenerates query code from sqlc.yaml.
+func Sqlc() Generator {
+	return Generator{Args: []string{"sqlc", "generate"}, Step: "sqlc"}
+}
+
+// Templ regenerates one .templ file, using the templ binary on PATH or the
+// module's go tool.
+func Templ(file string) Generator {
+	args := []string{"templ", "generate", "-f", file}
+	if _, err := exec.LookPath("templ"); err != nil {
+		args = append([]string{"go", "tool"}, args...)
+	}
+	return Generator{Args: args, Step: "templ"}
 }
 
 func Generate() error {
-	gen := exec.Command("go", "generate", "./...")
-	gen.Stdout = os.Stdout
-	gen.Stderr = os.Stderr
-	if err := gen.Run(); err != nil {
+	if _, err := capture(exec.Command("go", "generate", "./...")); err != nil {
 		return errors.Wrap(err, "generate")
 	}
 	return nil
 }
 
-func Run(in In) (Out, error) {
-	binDir, err := os.MkdirTemp("", "cheetah-build-*")
+// Build runs in.Generate and compiles in.Targets into the store, all at
+// once. The result is filled in whether or not the build succeeds; failed
+// builds leave nothing behind. A build canceled through ctx returns ctx's
+// error and an empty result status.
+func Build(ctx context.Context, in In) (Out, error) {
+	started := time.Now()
+	out := Out{Result: api.BuildResult{
+		Commit:    commit(),
+		ID:        api.NewID(started),
+		StartedAt: started,
+	}}
+
+	binDir, err := in.Store.Create(in.Space, out.Result.ID)
 	if err != nil {
-		return Out{}, errors.Wrap(err, "create temp dir")
+		return out, err
 	}
-	binPath := filepath.Join(binDir, "app")
 
-	if err := Generate(); err != nil {
-		return Out{}, err
+	gens := in.Generate
+	if gens == nil {
+		gens = []Generator{GoGenerate}
+	}
+	for _, g := range gens {
+		if output, err := capture(exec.CommandContext(ctx, g.Args[0], g.Args[1:]...)); err != nil {
+			in.Store.Delete(in.Space, out.Result.ID)
+			if ctx.Err() != nil {
+				return out, errors.Wrap(ctx.Err(), g.Step)
+			}
+			out.Result = fail(out.Result, g.Step, output)
+			return out, errors.Wrap(err, g.Step)
+		}
 	}
 
-	b := exec.Command("go", "build", "-o", binPath, "./cmd/app")
-	b.Stdout = os.Stdout
-	b.Stderr = os.Stderr
-	b.Env = append(os.Environ(),
-		fmt.Sprintf("DATABASE_URL=%s", in.DatabaseURL),
-	)
-	if err := b.Run(); err != nil {
-		return Out{}, errors.Wrap(err, "build")
+	targets := in.Targets
+	if targets == nil {
+		targets = DefaultTargets
+	}
+	outputs := make([]string, len(targets))
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Go(func() {
+			args := t.Args(filepath.Join(binDir, t.Name))
+			b := exec.CommandContext(ctx, args[0], args[1:]...)
+			b.Env = append(os.Environ(),
+				fmt.Sprintf("DATABASE_URL=%s", in.DatabaseURL),
+			)
+			outputs[i], errs[i] = capture(b)
+		})
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		in.Store.Delete(in.Space, out.Result.ID)
+		if ctx.Err() != nil {
+			return out, errors.Wrap(ctx.Err(), "build")
+		}
+		var output strings.Builder
+		for i, e := range errs {
+			if e != nil {
+				output.WriteString(outputs[i])
+			}
+		}
+		out.Result = fail(out.Result, "build", output.String())
+		return out, errors.Wrap(err, "build")
+	}
+
+	out.Binary = filepath.Join(binDir, targets[0].Name)
+	out.Workers = map[string]string{}
+	for _, t := range targets[1:] {
+		out.Workers[t.Name] = filepath.Join(binDir, t.Name)
+	}
+	out.Result.Errors = []api.BuildError{}
+	out.Result.FinishedAt = time.Now()
+	out.Result.Status = "success"
+	return out, nil
+}
+
+// commit describes the working tree, e.g. "3f2a9c1" or "3f2a9c1-dirty".
+func commit() string {
+	out, err := exec.Command("git", "describe", "--always", "--dirty").Output()
+	if err != nil {
+		return ""
 	}
+	return strings.TrimSpace(string(out))
+}
 
-	cmd := exec.Command(binPath)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Env = os.Environ()
+// Start launches a built binary with the space's env on in.Port. Workers
+// have no port and get no PORT.
+func Start(in In, binary string) (*exec.Cmd, error) {
+	cmd := exec.Command(binary)
+	cmd.Env = Env(in)
+	if err := start(in, cmd); err != nil {
+		return nil, err
+	}
+
+	if in.Port == 0 {
+		slog.Info("worker", "name", filepath.Base(binary), "pid", cmd.Process.Pid)
+	} else {
+		slog.Info("server", "port", in.Port, "pid", cmd.Process.Pid, "url", "http://localhost:50000")
+	}
+	return cmd, nil
+}
+
+// StartCommand launches a Procfile command with sh as a worker. The build's
+// binaries in binDir come first on PATH, so commands can run targets by
+// name. The command gets its own process group so stopping it stops
+// anything it spawned, like the binary under go run.
+func StartCommand(in In, command, binDir string) (*exec.Cmd, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(Env(in), "PATH="+binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := start(in, cmd); err != nil {
+		return nil, err
+	}
+
+	slog.Info("worker", "command", command, "pid", cmd.Process.Pid)
+	return cmd, nil
+}
+
+// Env is the environment processes in the space get: cheetah's own, the
+// app config, and the space's URLs, plus PORT for the web process.
+func Env(in In) []string {
+	env := os.Environ()
 	for k, v := range in.AppEnv {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
 	}
-	cmd.Env = append(cmd.Env,
+	env = append(env,
 		fmt.Sprintf("CHEETAH_URL=%s", in.CheetahURL),
 		fmt.Sprintf("DATABASE_TEMPLATE_URL=%s", in.DatabaseTemplateURL),
 		fmt.Sprintf("DATABASE_URL=%s", in.DatabaseURL),
-		fmt.Sprintf("PORT=%d", in.Port),
 		fmt.Sprintf("SPACE=%s", in.Space),
 	)
+	if in.Port != 0 {
+		env = append(env, fmt.Sprintf("PORT=%d", in.Port))
+	}
+	return env
+}
+
+func start(in In, cmd *exec.Cmd) error {
+	var w io.Writer = os.Stdout
+	if in.Output != nil {
+		w = in.Output
+	}
+	cmd.Stdout = w
+	cmd.Stderr = w
 	if err := cmd.Start(); err != nil {
-		return Out{}, errors.Wrap(err, "run")
+		return errors.Wrap(err, "run")
+	}
+	return nil
+}
+
+// capture runs cmd with its output going to the terminal as usual and
+// returns a copy of everything it printed.
+func capture(cmd *exec.Cmd) (string, error) {
+	var buf bytes.Buffer
+	cmd.Stdout = io.MultiWriter(os.Stdout, &buf)
+	cmd.Stderr = io.MultiWriter(os.Stderr, &buf)
+	err := cmd.Run()
+	return buf.String(), err
+}
+
+func fail(res api.BuildResult, step, output string) api.BuildResult {
+	dir, _ := os.Getwd()
+	res.Errors = ParseErrors(dir, output)
+	res.FinishedAt = time.Now()
+	res.Status = "failed"
+	res.Step = step
+	if len(output) > maxOutput {
+		output = output[len(output)-maxOutput:]
+	}
+	res.Output = output
+	return res
+}
+
+// ParseErrors extracts file:line:col diagnostics from go build, go generate,
+// templ and sqlc output. Paths are made relative to dir.
+func ParseErrors(dir, output string) []api.BuildError {
+	errs := []api.BuildError{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if m := templRe.FindStringSubmatch(line); m != nil {
+			errs = append(errs, api.BuildError{
+				Column:  atoi(m[4]),
+				File:    relPath(dir, m[1]),
+				Line:    atoi(m[3]),
+				Message: m[2],
+				Tool:    "templ",
+			})
+			continue
+		}
+
+		m := diagRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		e := api.BuildError{
+			Column:  atoi(m[3]),
+			File:    relPath(dir, m[1]),
+			Line:    atoi(m[2]),
+			Message: m[4],
+			Tool:    tool(m[1]),
+		}
+		if r := runningRe.FindStringSubmatch(m[4]); r != nil {
+			e.Tool = strings.Fields(r[1])[0]
+		}
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+func tool(file string) string {
+	switch filepath.Ext(file) {
+	case ".templ":
+		return "templ"
+	case ".sql", ".yaml", ".yml":
+		return "sqlc"
 	}
+	return "go"
+}
+
+func relPath(dir, path string) string {
+	if dir == "" || !filepath.IsAbs(path) {
+		return path
+	}
+	if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
 
-	slog.Info("server", "port", in.Port, "pid", cmd.Process.Pid, "url", "http://localhost:50000")
-	return Out{Cmd: cmd}, nil
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
 }
@@ -0,0 +1,63 @@
+package build_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/housecat-inc/cheetah/pkg/api"
+	"github.com/housecat-inc/cheetah/pkg/build"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		_name  string
+		dir    string
+		output string
+		out    []api.BuildError
+	}{
+		{
+			_name: "go build",
+			output: "# github.com/acme/app/cmd/app\n" +
+				"cmd/app/main.go:12:5: undefined: foo\n" +
+				"./cmd/app/main.go:14:2: declared and not used: x\n",
+			out: []api.BuildError{
+				{Column: 5, File: "cmd/app/main.go", Line: 12, Message: "undefined: foo", Tool: "go"},
+				{Column: 2, File: "cmd/app/main.go", Line: 14, Message: "declared and not used: x", Tool: "go"},
+			},
+		},
+		{
+			_name: "templ parse error",
+			dir:   "/src/app",
+			output: `(✗) Error [ error=failed to generate code for "/src/app/pkg/templates/greet.templ": /src/app/pkg/templates/greet.templ parsing error: <div>: close tag not found: line 5, col 1 ]` + "\n" +
+				"(✗) Command failed: generation completed with 1 errors\n" +
+				`main.go:3: running "templ": exit status 1` + "\n",
+			out: []api.BuildError{
+				{Column: 1, File: "pkg/templates/greet.templ", Line: 5, Message: "<div>: close tag not found", Tool: "templ"},
+				{File: "main.go", Line: 3, Message: `running "templ": exit status 1`, Tool: "templ"},
+			},
+		},
+		{
+			_name: "sqlc error",
+			output: "# package db\n" +
+				"pkg/db/query.sql:3:15: column \"nope\" does not exist\n" +
+				`main.go:4: running "sqlc": exit status 1` + "\n",
+			out: []api.BuildError{
+				{Column: 15, File: "pkg/db/query.sql", Line: 3, Message: `column "nope" does not exist`, Tool: "sqlc"},
+				{File: "main.go", Line: 4, Message: `running "sqlc": exit status 1`, Tool: "sqlc"},
+			},
+		},
+		{
+			_name:  "no diagnostics",
+			output: "go: downloading example.com/foo v1.0.0\n",
+			out:    []api.BuildError{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+			a.Equal(tt.out, build.ParseErrors(tt.dir, tt.output))
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package build
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Target is a main package to build into a binary called Name. The first
+// target of a space serves PORT; the rest run alongside it as workers.
+type Target struct {
+	Flags   []string `json:"flags,omitempty" yaml:"flags"`
+	LDFlags string   `json:"ldflags,omitempty" yaml:"ldflags"`
+	Name    string   `json:"name" yaml:"name"`
+	Package string   `json:"package" yaml:"package"`
+	Tags    []string `json:"tags,omitempty" yaml:"tags"`
+}
+
+var DefaultTargets = []Target{{Name: "app", Package: "./cmd/app"}}
+
+// Args is the go build command line that writes the target to out.
+func (t Target) Args(out string) []string {
+	args := []string{"go", "build", "-o", out}
+	args = append(args, t.Flags...)
+	if len(t.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(t.Tags, ","))
+	}
+	if t.LDFlags != "" {
+		args = append(args, "-ldflags", t.LDFlags)
+	}
+	return append(args, t.Package)
+}
+
+// LoadTargets reads the targets list from cheetah.yaml in dir, e.g.
+//
+//	targets:
+//	  - package: ./cmd/api
+//	  - package: ./cmd/worker
+//	    tags: [dev]
+//
+// Names default to the package's last element. It returns nil without a
+// cheetah.yaml or targets list.
+func LoadTargets(dir string) ([]Target, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "cheetah.yaml"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "read cheetah.yaml")
+	}
+
+	var cfg struct {
+		Targets []Target `yaml:"targets"`
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, errors.Wrap(err, "parse cheetah.yaml")
+	}
+	if len(cfg.Targets) == 0 {
+		return nil, nil
+	}
+	return CheckTargets(cfg.Targets)
+}
+
+// CheckTargets fills in default names and rejects targets without a
+// package or with a name already taken.
+func CheckTargets(targets []Target) ([]Target, error) {
+	out := make([]Target, 0, len(targets))
+	seen := map[string]bool{}
+	for _, t := range targets {
+		if t.Package == "" {
+			return nil, errors.Newf("target %q has no package", t.Name)
+		}
+		if t.Name == "" {
+			t.Name = path.Base(filepath.ToSlash(t.Package))
+		}
+		if seen[t.Name] {
+			return nil, errors.Newf("duplicate target %q", t.Name)
+		}
+		seen[t.Name] = true
+		out = append(out, t)
+	}
+	return out, nil
+}
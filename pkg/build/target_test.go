@@ -0,0 +1,73 @@
+package build_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/housecat-inc/cheetah/pkg/build"
+)
+
+func TestTargetArgs(t *testing.T) {
+	tests := []struct {
+		_name  string
+		target build.Target
+		out    []string
+	}{
+		{
+			_name:  "default",
+			target: build.DefaultTargets[0],
+			out:    []string{"go", "build", "-o", "bin/app", "./cmd/app"},
+		},
+		{
+			_name:  "flags tags and ldflags",
+			target: build.Target{Flags: []string{"-race"}, LDFlags: "-X main.version=dev", Name: "api", Package: "./cmd/api", Tags: []string{"dev", "sqlite"}},
+			out:    []string{"go", "build", "-o", "bin/app", "-race", "-tags", "dev,sqlite", "-ldflags", "-X main.version=dev", "./cmd/api"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+			a.Equal(tt.out, tt.target.Args("bin/app"))
+		})
+	}
+}
+
+func TestLoadTargets(t *testing.T) {
+	tests := []struct {
+		_name string
+		yaml  string
+		err   bool
+		out   []build.Target
+	}{
+		{_name: "no file"},
+		{_name: "no targets", yaml: "other: true\n"},
+		{
+			_name: "targets",
+			yaml:  "targets:\n  - package: ./cmd/api\n  - name: jobs\n    package: ./cmd/worker\n    tags: [dev]\n    ldflags: -s\n",
+			out: []build.Target{
+				{Name: "api", Package: "./cmd/api"},
+				{LDFlags: "-s", Name: "jobs", Package: "./cmd/worker", Tags: []string{"dev"}},
+			},
+		},
+		{_name: "missing package", yaml: "targets:\n  - name: api\n", err: true},
+		{_name: "duplicate name", yaml: "targets:\n  - package: ./cmd/api\n  - package: ./internal/api\n", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := t.TempDir()
+			if tt.yaml != "" {
+				os.WriteFile(filepath.Join(dir, "cheetah.yaml"), []byte(tt.yaml), 0o644)
+			}
+			out, err := build.LoadTargets(dir)
+			a.Equal(tt.err, err != nil)
+			a.Equal(tt.out, out)
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cockroachdb/errors"
 )
@@ -24,7 +25,9 @@ type Env struct {
 	Stat     func(string) (os.FileInfo, error)
 }
 
-func EnvOr[T string | int](key string, fallback T) T {
+// EnvOr reads key as a string, int or duration like "5s", returning fallback
+// when it is unset or doesn't parse.
+func EnvOr[T string | int | time.Duration](key string, fallback T) T {
 	v := os.Getenv(key)
 	if v == "" {
 		return fallback
@@ -38,6 +41,12 @@ func EnvOr[T string | int](key string, fallback T) T {
 			return fallback
 		}
 		return any(n).(T)
+	case time.Duration:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fallback
+		}
+		return any(d).(T)
 	}
 	return fallback
 }
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -202,6 +203,26 @@ func TestLoad(t *testing.T) {
 	}
 }
 
+func TestEnvOrDuration(t *testing.T) {
+	tests := []struct {
+		_name string
+		value string
+		out   time.Duration
+	}{
+		{_name: "unset", out: 5 * time.Second},
+		{_name: "set", value: "250ms", out: 250 * time.Millisecond},
+		{_name: "invalid", value: "often", out: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+			t.Setenv("CHEETAH_TEST_INTERVAL", tt.value)
+			a.Equal(tt.out, config.EnvOr("CHEETAH_TEST_INTERVAL", 5*time.Second))
+		})
+	}
+}
+
 func TestParseExample(t *testing.T) {
 	tests := []struct {
 		_name string
@@ -0,0 +1,90 @@
+package gotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"slices"
+
+	"github.com/cockroachdb/errors"
+)
+
+// listed is the part of go list -json that Affected uses.
+type listed struct {
+	Deps         []string
+	Dir          string
+	ImportPath   string
+	Module       *struct{ Main bool }
+	TestGoFiles  []string
+	TestImports  []string
+	XTestGoFiles []string
+	XTestImports []string
+}
+
+// Affected returns the import paths of the packages in the module at dir
+// whose tests could see a change to files: the packages the files are in
+// and every package that imports them, directly, transitively or from its
+// tests. Packages without test files are left out. A change to go.mod or
+// go.sum affects every package.
+func Affected(dir string, files []string) ([]string, error) {
+	cmd := exec.Command("go", "list", "-deps", "-e", "-json", "./...")
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "go list: %s", bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	pkgs := map[string]listed{}
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var p listed
+		if err := dec.Decode(&p); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "parse go list")
+		}
+		if p.Module != nil && p.Module.Main {
+			pkgs[p.ImportPath] = p
+		}
+	}
+
+	all := false
+	changed := map[string]bool{}
+	for _, f := range files {
+		if base := filepath.Base(f); base == "go.mod" || base == "go.sum" {
+			all = true
+		}
+		for _, p := range pkgs {
+			if p.Dir == filepath.Dir(f) {
+				changed[p.ImportPath] = true
+			}
+		}
+	}
+
+	affected := []string{}
+	for _, p := range pkgs {
+		if len(p.TestGoFiles)+len(p.XTestGoFiles) == 0 {
+			continue
+		}
+		if all || changed[p.ImportPath] || slices.ContainsFunc(testDeps(pkgs, p), func(d string) bool { return changed[d] }) {
+			affected = append(affected, p.ImportPath)
+		}
+	}
+	slices.Sort(affected)
+	return affected, nil
+}
+
+// testDeps is what p's test binary imports. go list only gives test
+// imports directly, so theirs come from the packages in the module.
+func testDeps(pkgs map[string]listed, p listed) []string {
+	deps := slices.Clone(p.Deps)
+	for _, imp := range slices.Concat(p.TestImports, p.XTestImports) {
+		deps = append(deps, imp)
+		deps = append(deps, pkgs[imp].Deps...)
+	}
+	return deps
+}
@@ -0,0 +1,371 @@
+package gotest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/housecat-inc/cheetah/pkg/api"
+)
+
+// maxOutput bounds what is kept of a failing test's output, from the end.
+const maxOutput = 4 << 10
+
+// In configures a go test run. Args follow go test -json and default to
+// ./...; Output gets the human-readable test output and Progress the run
+// so far each time a package finishes. Retries reruns failed tests up to
+// that many times.
+type In struct {
+	Args     []string
+	Dir      string
+	Env      []string
+	Output   io.Writer
+	Progress func(api.TestRun)
+	Retries  int
+}
+
+// Run runs go test -json and parses its events. The status is "pass",
+// "fail" when a test or package failed, including packages that don't
+// compile, or "error" when go test failed without saying why in its
+// events, as when it could not start. Failed tests that pass on a retry
+// count as passed and are marked flaky.
+func Run(ctx context.Context, in In) api.TestRun {
+	args := in.Args
+	if len(args) == 0 {
+		args = []string{"./..."}
+	}
+	run := api.TestRun{Args: args, StartedAt: time.Now()}
+
+	p := newParser(in.Output)
+	output, err := goTest(ctx, in, args, p, func() {
+		if in.Progress != nil {
+			partial := run
+			partial.Status = "running"
+			partial.Tests = slices.Clone(p.results)
+			count(&partial)
+			in.Progress(partial)
+		}
+	})
+
+	run.Tests = p.results
+	run.Output = output
+	count(&run)
+	failed := run.Failed > 0
+	if failed && in.Retries > 0 {
+		retry(ctx, in, &run)
+	}
+	run.FinishedAt = time.Now()
+	switch {
+	case run.Failed > 0:
+		run.Status = "fail"
+	case err != nil && !failed:
+		run.Status = "error"
+		if run.Output == "" {
+			run.Output = err.Error()
+		}
+	default:
+		run.Status = "pass"
+	}
+	return run
+}
+
+// goTest runs go test -json with args into p, calling done as each package
+// finishes. It returns the output that wasn't test events.
+func goTest(ctx context.Context, in In, args []string, p *parser, done func()) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "go", append([]string{"test", "-json"}, args...)...)
+	cmd.Dir = in.Dir
+	cmd.Env = in.Env
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		return err.Error(), err
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64<<10), 4<<20)
+	for scanner.Scan() {
+		if p.line(scanner.Bytes()) {
+			done()
+		}
+	}
+	err = cmd.Wait()
+	return tail(strings.TrimSpace(p.other.String() + stderr.String())), err
+}
+
+// retry reruns the top-level tests behind run's failures, a package at a
+// time with the flags of in.Args that don't pick tests. A failure whose
+// test passes on a retry becomes a flaky pass.
+func retry(ctx context.Context, in In, run *api.TestRun) {
+	for range in.Retries {
+		failed := map[string][]string{}
+		for _, r := range run.Tests {
+			top, _, _ := strings.Cut(r.Test, "/")
+			if r.Status == "fail" && r.Test != "" && !slices.Contains(failed[r.Package], top) {
+				failed[r.Package] = append(failed[r.Package], top)
+			}
+		}
+		if len(failed) == 0 || ctx.Err() != nil {
+			return
+		}
+
+		for pkg, names := range failed {
+			for i, name := range names {
+				names[i] = regexp.QuoteMeta(name)
+			}
+			args := append(retryFlags(in.Args), "-count=1", "-run", "^("+strings.Join(names, "|")+")$", pkg)
+			p := newParser(in.Output)
+			goTest(ctx, in, args, p, func() {})
+
+			passed := map[string]bool{}
+			for _, r := range p.results {
+				if r.Status == "pass" {
+					passed[r.Test] = true
+				}
+			}
+			for i, r := range run.Tests {
+				if r.Package == pkg && r.Status == "fail" && passed[r.Test] {
+					run.Tests[i].Flaky = true
+					run.Tests[i].Status = "pass"
+				}
+			}
+		}
+		count(run)
+	}
+}
+
+// retryFlags keeps the flags of args that carry over to a retry: known
+// boolean flags and -flag=value forms, except those that pick tests.
+func retryFlags(args []string) []string {
+	flags := []string{}
+	for _, a := range args {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		switch {
+		case !strings.HasPrefix(a, "-"), name == "run", name == "skip", name == "count", name == "json":
+		case hasValue, slices.Contains([]string{"cover", "failfast", "race", "short", "v"}, name):
+			flags = append(flags, a)
+		}
+	}
+	return flags
+}
+
+// Parse reads go test -json output into results in the order tests finish.
+func Parse(r io.Reader) []api.TestResult {
+	p := newParser(nil)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 4<<20)
+	for scanner.Scan() {
+		p.line(scanner.Bytes())
+	}
+	return p.results
+}
+
+// event is a test2json record.
+type event struct {
+	Action      string
+	Elapsed     float64
+	FailedBuild string
+	ImportPath  string
+	Output      string
+	Package     string
+	Test        string
+}
+
+type parser struct {
+	build   map[string]*strings.Builder
+	failed  map[string]bool
+	other   strings.Builder
+	out     io.Writer
+	output  map[string]*strings.Builder
+	results []api.TestResult
+}
+
+func newParser(out io.Writer) *parser {
+	return &parser{
+		build:   map[string]*strings.Builder{},
+		failed:  map[string]bool{},
+		out:     out,
+		output:  map[string]*strings.Builder{},
+		results: []api.TestResult{},
+	}
+}
+
+// line parses one line of go test -json output and reports whether it
+// finished a package.
+func (p *parser) line(b []byte) bool {
+	var e event
+	if len(b) == 0 || b[0] != '{' || json.Unmarshal(b, &e) != nil {
+		p.other.Write(b)
+		p.other.WriteByte('\n')
+		return false
+	}
+	if p.out != nil && e.Output != "" {
+		io.WriteString(p.out, e.Output)
+	}
+
+	switch e.Action {
+	case "build-output":
+		buf(p.build, e.ImportPath).WriteString(e.Output)
+	case "output":
+		if !framing(e.Output, e.Test == "") {
+			buf(p.output, e.Package+" "+e.Test).WriteString(e.Output)
+		}
+	case "pass", "skip", "fail":
+		key := e.Package + " " + e.Test
+		out := p.output[key]
+		delete(p.output, key)
+		if e.Test == "" {
+			// Packages only count when they fail without a failing test,
+			// like a build failure or a panic in TestMain.
+			if e.Action != "fail" || p.failed[e.Package] {
+				return true
+			}
+			pkgOut := out
+			out = &strings.Builder{}
+			if b, ok := p.build[e.FailedBuild]; ok {
+				out.WriteString(b.String())
+			}
+			if pkgOut != nil {
+				out.WriteString(pkgOut.String())
+			}
+		}
+		if e.Action == "fail" {
+			p.failed[e.Package] = true
+		}
+		r := api.TestResult{
+			Elapsed: time.Duration(e.Elapsed * float64(time.Second)),
+			Package: e.Package,
+			Status:  e.Action,
+			Test:    e.Test,
+		}
+		if e.Action != "pass" && out != nil {
+			r.Output = tail(strings.TrimRight(out.String(), "\n"))
+		}
+		p.results = append(p.results, r)
+		return e.Test == ""
+	}
+	return false
+}
+
+func buf(m map[string]*strings.Builder, key string) *strings.Builder {
+	b, ok := m[key]
+	if !ok {
+		b = &strings.Builder{}
+		m[key] = b
+	}
+	return b
+}
+
+// framing reports lines go test prints around test output, which results
+// already say through their status.
+func framing(line string, pkg bool) bool {
+	for _, prefix := range []string{"=== RUN", "=== PAUSE", "=== CONT", "=== NAME", "--- PASS", "--- FAIL", "--- SKIP"} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	if !pkg {
+		return false
+	}
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "PASS" || trimmed == "FAIL" || strings.HasPrefix(line, "ok  \t") ||
+		strings.HasPrefix(line, "FAIL\t") || strings.HasPrefix(line, "?   \t") || strings.HasPrefix(trimmed, "coverage:")
+}
+
+func tail(s string) string {
+	if len(s) <= maxOutput {
+		return s
+	}
+	s = s[len(s)-maxOutput:]
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	return "...\n" + s
+}
+
+// count tallies tests by status. Package results are only kept for
+// packages that failed on their own, so they count as failures.
+func count(run *api.TestRun) {
+	run.Failed, run.Flaky, run.Passed, run.Skipped = 0, 0, 0, 0
+	for _, r := range run.Tests {
+		if r.Flaky {
+			run.Flaky++
+		}
+		switch {
+		case r.Status == "fail":
+			run.Failed++
+		case r.Test == "":
+		case r.Status == "pass":
+			run.Passed++
+		case r.Status == "skip":
+			run.Skipped++
+		}
+	}
+}
+
+// Summary is a compact report of run for people and agents: the counts,
+// then each failure with its output and each test that only passed on a
+// retry. Parents of failed or flaky subtests that printed nothing
+// themselves are left out.
+func Summary(run api.TestRun) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d passed, %d failed, %d skipped", run.Status, run.Passed, run.Failed, run.Skipped)
+	if run.Flaky > 0 {
+		fmt.Fprintf(&b, ", %d flaky", run.Flaky)
+	}
+	fmt.Fprintf(&b, " in %s\n", run.FinishedAt.Sub(run.StartedAt).Round(10*time.Millisecond))
+
+	failed := func(r api.TestResult) bool { return r.Status == "fail" }
+	retried := func(r api.TestResult) bool { return r.Status == "pass" && r.Flaky }
+	for _, r := range run.Tests {
+		label := "FAIL"
+		switch {
+		case failed(r) && (r.Output != "" || !hasChild(run.Tests, r, failed)):
+		case retried(r) && (r.Output != "" || !hasChild(run.Tests, r, retried)):
+			label = "FLAKY"
+		default:
+			continue
+		}
+		name := r.Package
+		if r.Test != "" {
+			name += "." + r.Test
+		}
+		note := ""
+		if label == "FLAKY" {
+			note = ", passed on retry"
+		} else if r.Flaky {
+			note = ", flaky"
+		}
+		fmt.Fprintf(&b, "\n%s %s (%s%s)\n", label, name, r.Elapsed.Round(time.Millisecond), note)
+		if r.Output != "" {
+			b.WriteString(r.Output + "\n")
+		}
+	}
+	if run.Status == "error" && run.Output != "" {
+		b.WriteString("\n" + run.Output + "\n")
+	}
+	return b.String()
+}
+
+func hasChild(results []api.TestResult, parent api.TestResult, match func(api.TestResult) bool) bool {
+	if parent.Test == "" {
+		return false
+	}
+	for _, r := range results {
+		if match(r) && r.Package == parent.Package && strings.HasPrefix(r.Test, parent.Test+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,295 @@
+package gotest_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/housecat-inc/cheetah/pkg/api"
+	"github.com/housecat-inc/cheetah/pkg/gotest"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		_name string
+		in    string
+		out   []api.TestResult
+	}{
+		{
+			_name: "pass and fail",
+			in: `{"Action":"run","Package":"example.com/greet","Test":"TestOK"}
+{"Action":"output","Package":"example.com/greet","Test":"TestOK","Output":"=== RUN   TestOK\n"}
+{"Action":"output","Package":"example.com/greet","Test":"TestOK","Output":"    greet_test.go:5: noisy\n"}
+{"Action":"pass","Package":"example.com/greet","Test":"TestOK","Elapsed":0.5}
+{"Action":"run","Package":"example.com/greet","Test":"TestBoom"}
+{"Action":"output","Package":"example.com/greet","Test":"TestBoom","Output":"=== RUN   TestBoom\n"}
+{"Action":"output","Package":"example.com/greet","Test":"TestBoom","Output":"    greet_test.go:9: want 1, got 2\n"}
+{"Action":"output","Package":"example.com/greet","Test":"TestBoom","Output":"--- FAIL: TestBoom (0.01s)\n"}
+{"Action":"fail","Package":"example.com/greet","Test":"TestBoom","Elapsed":0.01}
+{"Action":"output","Package":"example.com/greet","Output":"FAIL\n"}
+{"Action":"fail","Package":"example.com/greet","Elapsed":0.6}
+`,
+			out: []api.TestResult{
+				{Elapsed: 500 * time.Millisecond, Package: "example.com/greet", Status: "pass", Test: "TestOK"},
+				{Elapsed: 10 * time.Millisecond, Output: "    greet_test.go:9: want 1, got 2", Package: "example.com/greet", Status: "fail", Test: "TestBoom"},
+			},
+		},
+		{
+			_name: "skip keeps reason",
+			in: `{"Action":"output","Package":"example.com/greet","Test":"TestDB","Output":"    db_test.go:3: no postgres\n"}
+{"Action":"skip","Package":"example.com/greet","Test":"TestDB"}
+{"Action":"pass","Package":"example.com/greet"}
+`,
+			out: []api.TestResult{
+				{Output: "    db_test.go:3: no postgres", Package: "example.com/greet", Status: "skip", Test: "TestDB"},
+			},
+		},
+		{
+			_name: "build failure",
+			in: `{"ImportPath":"example.com/greet [example.com/greet.test]","Action":"build-output","Output":"# example.com/greet\n"}
+{"ImportPath":"example.com/greet [example.com/greet.test]","Action":"build-output","Output":"./greet.go:3:9: undefined: x\n"}
+{"ImportPath":"example.com/greet [example.com/greet.test]","Action":"build-fail"}
+{"Action":"start","Package":"example.com/greet"}
+{"Action":"output","Package":"example.com/greet","Output":"FAIL\texample.com/greet [build failed]\n"}
+{"Action":"fail","Package":"example.com/greet","FailedBuild":"example.com/greet [example.com/greet.test]"}
+`,
+			out: []api.TestResult{
+				{Output: "# example.com/greet\n./greet.go:3:9: undefined: x", Package: "example.com/greet", Status: "fail"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+			a.Equal(tt.out, gotest.Parse(strings.NewReader(tt.in)))
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/greet\n\ngo 1.21\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "greet_test.go"), []byte(`package greet
+
+import "testing"
+
+func TestOK(t *testing.T) {}
+
+func TestTable(t *testing.T) {
+	t.Run("good", func(t *testing.T) {})
+	t.Run("bad", func(t *testing.T) { t.Error("want 1, got 2") })
+}
+
+func TestSkip(t *testing.T) { t.Skip("later") }
+`), 0o644)
+
+	var progress []api.TestRun
+	run := gotest.Run(context.Background(), gotest.In{Dir: dir, Env: os.Environ(), Progress: func(r api.TestRun) { progress = append(progress, r) }})
+	a.Equal("fail", run.Status)
+	if a.Len(progress, 1) {
+		a.Equal("running", progress[0].Status)
+		a.Equal(2, progress[0].Failed)
+	}
+	a.Equal([]string{"./..."}, run.Args)
+	a.Equal(2, run.Passed)
+	a.Equal(2, run.Failed)
+	a.Equal(1, run.Skipped)
+
+	summary := gotest.Summary(run)
+	a.Contains(summary, "fail: 2 passed, 2 failed, 1 skipped")
+	a.Contains(summary, "FAIL example.com/greet.TestTable/bad")
+	a.Contains(summary, "want 1, got 2")
+	a.NotContains(summary, "FAIL example.com/greet.TestTable (")
+}
+
+func TestAffected(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":              "module example.com/shop\n\ngo 1.21\n",
+		"money/money.go":      "package money\n",
+		"money/money_test.go": "package money\n",
+		"cart/cart.go":        "package cart\n\nimport _ \"example.com/shop/money\"\n",
+		"cart/cart_test.go":   "package cart\n",
+		"web/web.go":          "package web\n\nimport _ \"example.com/shop/cart\"\n",
+		"fake/fake.go":        "package fake\n\nimport _ \"example.com/shop/money\"\n",
+		"e2e/e2e_test.go":     "package e2e\n\nimport _ \"example.com/shop/fake\"\n",
+		"mail/mail_test.go":   "package mail\n",
+		"migrations/1.sql":    "select 1;\n",
+	}
+	for name, content := range files {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755)
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+	}
+
+	tests := []struct {
+		_name string
+		files []string
+		out   []string
+	}{
+		{_name: "leaf", files: []string{"money/money.go"}, out: []string{"example.com/shop/cart", "example.com/shop/e2e", "example.com/shop/money"}},
+		{_name: "importer", files: []string{"cart/cart.go"}, out: []string{"example.com/shop/cart"}},
+		{_name: "no tests", files: []string{"web/web.go"}, out: []string{}},
+		{_name: "test only import", files: []string{"fake/fake.go"}, out: []string{"example.com/shop/e2e"}},
+		{_name: "not a package", files: []string{"migrations/1.sql"}, out: []string{}},
+		{_name: "go.mod", files: []string{"go.mod"}, out: []string{"example.com/shop/cart", "example.com/shop/e2e", "example.com/shop/mail", "example.com/shop/money"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+
+			var paths []string
+			for _, f := range tt.files {
+				paths = append(paths, filepath.Join(dir, f))
+			}
+			out, err := gotest.Affected(dir, paths)
+			a.NoError(err)
+			a.Equal(tt.out, out)
+		})
+	}
+}
+
+func TestRunRetries(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/flaky\n\ngo 1.21\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "flaky_test.go"), []byte(`package flaky
+
+import (
+	"os"
+	"testing"
+)
+
+func TestOK(t *testing.T) {}
+
+func TestFirstTry(t *testing.T) {
+	if _, err := os.Stat("tried"); err != nil {
+		os.WriteFile("tried", nil, 0o644)
+		t.Fatal("first try")
+	}
+}
+`), 0o644)
+
+	run := gotest.Run(context.Background(), gotest.In{Args: []string{"-short", "-timeout=1m", "./..."}, Dir: dir, Env: os.Environ(), Retries: 2})
+	a.Equal("pass", run.Status)
+	a.Equal(2, run.Passed)
+	a.Equal(0, run.Failed)
+	a.Equal(1, run.Flaky)
+	a.Contains(gotest.Summary(run), "FLAKY example.com/flaky.TestFirstTry")
+	a.Contains(gotest.Summary(run), "first try")
+}
+
+func TestFlaky(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		_name    string
+		outcomes []gotest.Outcome
+		out      []gotest.Flake
+	}{
+		{_name: "none", outcomes: []gotest.Outcome{}, out: []gotest.Flake{}},
+		{
+			_name: "fixed between commits",
+			outcomes: []gotest.Outcome{
+				{Commit: "a", Package: "p", Status: "fail", Test: "TestX", Time: now},
+				{Commit: "b", Package: "p", Status: "pass", Test: "TestX", Time: now},
+			},
+			out: []gotest.Flake{},
+		},
+		{
+			_name: "same commit",
+			outcomes: []gotest.Outcome{
+				{Commit: "a", Package: "p", Status: "pass", Test: "TestX", Time: now.Add(-time.Hour)},
+				{Commit: "a", Package: "p", Status: "fail", Test: "TestX", Time: now.Add(-time.Hour)},
+				{Commit: "b", Package: "p", Status: "pass", Test: "TestX", Time: now},
+				{Commit: "b", Package: "p", Status: "pass", Test: "TestX", Time: now},
+				{Commit: "b", Package: "p", Status: "fail", Test: "TestX", Time: now},
+				{Commit: "b", Package: "p", Status: "pass", Test: "TestY", Time: now},
+				{Commit: "b", Package: "q", Status: "fail", Test: "TestZ", Time: now.Add(-2 * time.Hour)},
+				{Commit: "b", Package: "q", Status: "pass", Test: "TestZ", Time: now.Add(-2 * time.Hour)},
+			},
+			out: []gotest.Flake{
+				{Commits: 2, Failed: 2, LastSeen: now, Package: "p", Passed: 3, Test: "TestX"},
+				{Commits: 1, Failed: 1, LastSeen: now.Add(-2 * time.Hour), Package: "q", Passed: 1, Test: "TestZ"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+			a.Equal(tt.out, gotest.Flaky(tt.outcomes))
+		})
+	}
+}
+
+func TestHistory(t *testing.T) {
+	a := assert.New(t)
+
+	h := gotest.History{Path: filepath.Join(t.TempDir(), "tests", "history.jsonl")}
+	out, err := h.Load()
+	a.NoError(err)
+	a.Empty(out)
+
+	run := api.TestRun{FinishedAt: time.Now(), Tests: []api.TestResult{
+		{Package: "p", Status: "pass", Test: "TestX"},
+		{Package: "p", Status: "skip", Test: "TestSkip"},
+		{Package: "p", Status: "fail"},
+	}}
+	a.NoError(h.Record("abc", run))
+	run.Tests[0] = api.TestResult{Flaky: true, Package: "p", Status: "pass", Test: "TestY"}
+	a.NoError(h.Record("abc", run))
+
+	out, err = h.Load()
+	a.NoError(err)
+	a.Len(out, 3)
+
+	run.Tests = []api.TestResult{{Package: "p", Status: "fail", Test: "TestX"}, {Package: "p", Status: "pass", Test: "TestY"}}
+	a.NoError(h.Record("abc", run))
+	out, _ = h.Load()
+	gotest.MarkFlaky(&run, "abc", out)
+	a.True(run.Tests[0].Flaky)
+	a.True(run.Tests[1].Flaky)
+	a.Equal(2, run.Flaky)
+}
+
+func TestHistoryCompactKeepsAppends(t *testing.T) {
+	a := assert.New(t)
+
+	h := gotest.History{Path: filepath.Join(t.TempDir(), "history.jsonl")}
+	line := `{"commit":"old","package":"p","status":"pass","test":"TestOld","time":"2026-01-01T00:00:00Z"}` + "\n"
+	a.NoError(os.WriteFile(h.Path, []byte(strings.Repeat(line, 9<<20/len(line))), 0o644))
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run := api.TestRun{FinishedAt: time.Now(), Tests: []api.TestResult{{Package: "p", Status: "pass", Test: "TestNew"}}}
+			a.NoError(h.Record(fmt.Sprintf("c%d", i), run))
+		}()
+	}
+	wg.Wait()
+
+	out, err := h.Load()
+	a.NoError(err)
+	var commits []string
+	for _, o := range out {
+		if o.Test == "TestNew" {
+			commits = append(commits, o.Commit)
+		}
+	}
+	a.ElementsMatch([]string{"c0", "c1", "c2", "c3", "c4", "c5", "c6", "c7"}, commits)
+	entries, _ := os.ReadDir(filepath.Dir(h.Path))
+	a.Len(entries, 2, "only the history and its lock are left")
+}
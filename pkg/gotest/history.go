@@ -0,0 +1,265 @@
+package gotest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/housecat-inc/cheetah/pkg/api"
+)
+
+// maxHistory is the size past which History drops its older half.
+const maxHistory = 8 << 20
+
+// Outcome is how a test did at a commit.
+type Outcome struct {
+	Commit  string    `json:"commit"`
+	Package string    `json:"package"`
+	Status  string    `json:"status"`
+	Test    string    `json:"test"`
+	Time    time.Time `json:"time"`
+}
+
+// Flake is a test that both passed and failed at the same commit.
+type Flake struct {
+	Commits  int       `json:"commits"`
+	Failed   int       `json:"failed"`
+	LastSeen time.Time `json:"last_seen"`
+	Package  string    `json:"package"`
+	Passed   int       `json:"passed"`
+	Test     string    `json:"test"`
+}
+
+// History is an append-only log of test outcomes shared by every space.
+type History struct {
+	Path string
+}
+
+func DefaultHistory() History {
+	home, _ := os.UserHomeDir()
+	return History{Path: filepath.Join(home, ".cheetah", "tests", "history.jsonl")}
+}
+
+// Record appends the pass and fail outcomes of the tests in run. Tests that
+// passed on a retry count as a failure and a pass.
+func (h History) Record(commit string, run api.TestRun) error {
+	if commit == "" {
+		return nil
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	for _, r := range run.Tests {
+		if r.Test == "" || r.Status == "skip" {
+			continue
+		}
+		o := Outcome{Commit: commit, Package: r.Package, Status: r.Status, Test: r.Test, Time: run.FinishedAt}
+		if r.Flaky && r.Status == "pass" {
+			enc.Encode(Outcome{Commit: commit, Package: r.Package, Status: "fail", Test: r.Test, Time: run.FinishedAt})
+		}
+		enc.Encode(o)
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(h.Path), 0o755); err != nil {
+		return errors.Wrap(err, "create test history dir")
+	}
+	unlock, err := h.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(h.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errors.Wrap(err, "open test history")
+	}
+	_, err = f.Write(b.Bytes())
+	f.Close()
+	if err != nil {
+		return errors.Wrap(err, "write test history")
+	}
+	return h.compact()
+}
+
+// Load reads every outcome, skipping lines it can't parse.
+func (h History) Load() ([]Outcome, error) {
+	data, err := os.ReadFile(h.Path)
+	if os.IsNotExist(err) {
+		return []Outcome{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "read test history")
+	}
+	outcomes := []Outcome{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var o Outcome
+		if json.Unmarshal(scanner.Bytes(), &o) == nil {
+			outcomes = append(outcomes, o)
+		}
+	}
+	return outcomes, nil
+}
+
+// lock takes the lock every cheetah process holds to write the history, so
+// compact can't drop lines another run appends.
+func (h History) lock() (func(), error) {
+	f, err := os.OpenFile(h.Path+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, errors.Wrap(err, "open test history lock")
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "lock test history")
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// compact drops the older half of the history once it is too big. Callers
+// hold the lock.
+func (h History) compact() error {
+	info, err := os.Stat(h.Path)
+	if err != nil || info.Size() <= maxHistory {
+		return nil
+	}
+	data, err := os.ReadFile(h.Path)
+	if err != nil {
+		return errors.Wrap(err, "read test history")
+	}
+	data = data[len(data)/2:]
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		data = data[i+1:]
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(h.Path), "history-*.jsonl")
+	if err != nil {
+		return errors.Wrap(err, "create test history temp")
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errors.Wrap(err, "write test history")
+	}
+	return errors.Wrap(os.Rename(tmp.Name(), h.Path), "replace test history")
+}
+
+// Flaky finds the tests that both passed and failed at one commit, most
+// recently seen first. Counts cover only the commits where they did both.
+func Flaky(outcomes []Outcome) []Flake {
+	type key struct{ commit, pkg, test string }
+	type tally struct {
+		failed, passed int
+		last           time.Time
+	}
+	tallies := map[key]*tally{}
+	for _, o := range outcomes {
+		k := key{o.Commit, o.Package, o.Test}
+		t, ok := tallies[k]
+		if !ok {
+			t = &tally{}
+			tallies[k] = t
+		}
+		switch o.Status {
+		case "fail":
+			t.failed++
+		case "pass":
+			t.passed++
+		}
+		if o.Time.After(t.last) {
+			t.last = o.Time
+		}
+	}
+
+	flakes := map[[2]string]*Flake{}
+	for k, t := range tallies {
+		if t.failed == 0 || t.passed == 0 {
+			continue
+		}
+		f, ok := flakes[[2]string{k.pkg, k.test}]
+		if !ok {
+			f = &Flake{Package: k.pkg, Test: k.test}
+			flakes[[2]string{k.pkg, k.test}] = f
+		}
+		f.Commits++
+		f.Failed += t.failed
+		f.Passed += t.passed
+		if t.last.After(f.LastSeen) {
+			f.LastSeen = t.last
+		}
+	}
+
+	out := []Flake{}
+	for _, f := range flakes {
+		out = append(out, *f)
+	}
+	slices.SortFunc(out, func(a, b Flake) int {
+		if c := b.LastSeen.Compare(a.LastSeen); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Package+"."+a.Test, b.Package+"."+b.Test)
+	})
+	return out
+}
+
+// MarkFlaky flags the results in run that outcomes show passing and failing
+// at commit.
+func MarkFlaky(run *api.TestRun, commit string, outcomes []Outcome) {
+	flaky := map[[2]string]bool{}
+	for _, f := range Flaky(slices.DeleteFunc(slices.Clone(outcomes), func(o Outcome) bool { return o.Commit != commit })) {
+		flaky[[2]string{f.Package, f.Test}] = true
+	}
+	for i, r := range run.Tests {
+		if flaky[[2]string{r.Package, r.Test}] {
+			run.Tests[i].Flaky = true
+		}
+	}
+	count(run)
+}
+
+// Commit identifies the code in dir: the HEAD commit, plus a hash of the
+// uncommitted changes and untracked files when there are any, so edits
+// between runs aren't mistaken for flakiness. It is empty outside git.
+func Commit(dir string) string {
+	git := func(args ...string) ([]byte, error) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		return cmd.Output()
+	}
+	head, err := git("rev-parse", "--short", "HEAD")
+	if err != nil {
+		return ""
+	}
+	commit := strings.TrimSpace(string(head))
+
+	diff, _ := git("diff", "HEAD")
+	untracked, _ := git("ls-files", "--others", "--exclude-standard", "-z")
+	if len(diff) == 0 && len(untracked) == 0 {
+		return commit
+	}
+	sum := sha256.New()
+	sum.Write(diff)
+	for _, name := range strings.Split(strings.TrimRight(string(untracked), "\x00"), "\x00") {
+		data, _ := os.ReadFile(filepath.Join(dir, name))
+		sum.Write([]byte(name))
+		sum.Write(data)
+	}
+	return commit + "-" + hex.EncodeToString(sum.Sum(nil))[:8]
+}
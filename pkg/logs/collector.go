@@ -0,0 +1,356 @@
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/housecat-inc/cheetah/pkg/api"
+)
+
+const (
+	flushInterval = 500 * time.Millisecond
+	maxBatch      = 50
+)
+
+var (
+	ansiRe  = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	clockRe = regexp.MustCompile(`^\d{1,4}[:/-]\d{1,2}([:/-]\d{1,4})?([.:]\d+)*(AM|PM)?$`)
+)
+
+// Collector is an io.Writer for child process output. It tees bytes to Out,
+// parses each complete line into an api.Log and posts them in batches.
+type Collector struct {
+	Out  io.Writer
+	Post func([]api.Log)
+
+	batch   []api.Log
+	buf     []byte
+	mu      sync.Mutex
+	procs   map[string]*processWriter
+	stop    chan struct{}
+	stopped sync.WaitGroup
+}
+
+func NewCollector(out io.Writer, post func([]api.Log)) *Collector {
+	c := &Collector{
+		Out:   out,
+		Post:  post,
+		procs: map[string]*processWriter{},
+		stop:  make(chan struct{}),
+	}
+
+	c.stopped.Add(1)
+	go func() {
+		defer c.stopped.Done()
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				c.Flush()
+			}
+		}
+	}()
+
+	return c
+}
+
+func (c *Collector) Write(p []byte) (int, error) {
+	return c.write(&c.buf, "", p)
+}
+
+// Process is a writer for the output of a named process, like a worker.
+// Its records get a "process" attr. Each name has one writer, so Close can
+// flush its last partial line.
+func (c *Collector) Process(name string) io.Writer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w, ok := c.procs[name]
+	if !ok {
+		w = &processWriter{c: c, name: name}
+		c.procs[name] = w
+	}
+	return w
+}
+
+type processWriter struct {
+	buf  []byte
+	c    *Collector
+	name string
+}
+
+func (w *processWriter) Write(p []byte) (int, error) {
+	return w.c.write(&w.buf, w.name, p)
+}
+
+func (c *Collector) write(buf *[]byte, process string, p []byte) (int, error) {
+	if c.Out != nil {
+		c.Out.Write(p)
+	}
+
+	c.mu.Lock()
+	*buf = append(*buf, p...)
+	for {
+		i := bytes.IndexByte(*buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := string((*buf)[:i])
+		*buf = (*buf)[i+1:]
+		c.addLocked(line, process)
+	}
+	full := len(c.batch) >= maxBatch
+	c.mu.Unlock()
+
+	if full {
+		c.Flush()
+	}
+	return len(p), nil
+}
+
+func (c *Collector) addLocked(line, process string) {
+	l, ok := Parse(line)
+	if !ok {
+		return
+	}
+	if process != "" {
+		if l.Attrs == nil {
+			l.Attrs = map[string]any{}
+		}
+		l.Attrs["process"] = process
+	}
+	c.batch = append(c.batch, l)
+}
+
+func (c *Collector) Flush() {
+	c.mu.Lock()
+	batch := c.batch
+	c.batch = nil
+	c.mu.Unlock()
+
+	if len(batch) > 0 && c.Post != nil {
+		c.Post(batch)
+	}
+}
+
+// Close stops flushing and posts what is left, including the last partial
+// line of every process.
+func (c *Collector) Close() {
+	close(c.stop)
+	c.stopped.Wait()
+
+	c.mu.Lock()
+	c.addLocked(string(c.buf), "")
+	c.buf = nil
+	for _, name := range slices.Sorted(maps.Keys(c.procs)) {
+		w := c.procs[name]
+		c.addLocked(string(w.buf), name)
+		w.buf = nil
+	}
+	c.mu.Unlock()
+
+	c.Flush()
+}
+
+// Parse turns one line of output into a log record. It understands slog JSON,
+// slog text (logfmt) and tint/log-style "TIME LVL msg k=v" lines; anything
+// else becomes an info record with the raw line as its message.
+func Parse(line string) (api.Log, bool) {
+	line = strings.TrimSpace(ansiRe.ReplaceAllString(line, ""))
+	if line == "" {
+		return api.Log{}, false
+	}
+
+	if strings.HasPrefix(line, "{") {
+		if l, ok := parseJSON(line); ok {
+			return l, true
+		}
+	}
+
+	if strings.Contains(line, "level=") && strings.Contains(line, "msg=") {
+		if l, ok := parseText(line); ok {
+			return l, true
+		}
+	}
+
+	return parsePretty(line), true
+}
+
+func parseJSON(line string) (api.Log, bool) {
+	var m map[string]any
+	if err := json.Unmarshal([]byte(line), &m); err != nil {
+		return api.Log{}, false
+	}
+	msg, ok := m["msg"].(string)
+	if !ok {
+		return api.Log{}, false
+	}
+
+	l := api.Log{Message: msg, Timestamp: time.Now()}
+	if lvl, ok := m["level"].(string); ok {
+		l.Level = level(lvl)
+	}
+	if ts, ok := m["time"].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			l.Timestamp = t
+		}
+	}
+	delete(m, "level")
+	delete(m, "msg")
+	delete(m, "time")
+	if len(m) > 0 {
+		l.Attrs = m
+	}
+	if l.Level == "" {
+		l.Level = "info"
+	}
+	return l, true
+}
+
+func parseText(line string) (api.Log, bool) {
+	l := api.Log{Timestamp: time.Now()}
+	attrs := map[string]any{}
+	found := false
+	for _, kv := range logfmt(line) {
+		switch kv[0] {
+		case "level":
+			l.Level = level(kv[1])
+		case "msg":
+			l.Message = kv[1]
+			found = true
+		case "time":
+			if t, err := time.Parse(time.RFC3339Nano, kv[1]); err == nil {
+				l.Timestamp = t
+			}
+		default:
+			attrs[kv[0]] = kv[1]
+		}
+	}
+	if !found {
+		return api.Log{}, false
+	}
+	if len(attrs) > 0 {
+		l.Attrs = attrs
+	}
+	if l.Level == "" {
+		l.Level = "info"
+	}
+	return l, true
+}
+
+func parsePretty(line string) api.Log {
+	l := api.Log{Level: "info", Message: line, Timestamp: time.Now()}
+
+	if strings.HasPrefix(line, "panic:") || strings.HasPrefix(line, "fatal error:") {
+		l.Level = "error"
+		return l
+	}
+
+	rest := line
+	for i := 0; i < 2; i++ {
+		tok, after, _ := strings.Cut(rest, " ")
+		if !clockRe.MatchString(tok) {
+			break
+		}
+		rest = after
+	}
+
+	tok, after, _ := strings.Cut(rest, " ")
+	lvl := level(strings.SplitN(tok, "+", 2)[0])
+	if lvl == "" {
+		return l
+	}
+	l.Level = lvl
+
+	// The message runs until the first key=value token.
+	msg := after
+	if i := attrStart(after); i >= 0 {
+		msg = after[:i]
+		attrs := map[string]any{}
+		for _, kv := range logfmt(after[i:]) {
+			attrs[kv[0]] = kv[1]
+		}
+		if len(attrs) > 0 {
+			l.Attrs = attrs
+		}
+	}
+	l.Message = strings.TrimSpace(msg)
+	return l
+}
+
+func level(s string) string {
+	switch strings.ToUpper(s) {
+	case "DBG", "DEBUG":
+		return "debug"
+	case "INF", "INFO":
+		return "info"
+	case "WRN", "WARN", "WARNING":
+		return "warn"
+	case "ERR", "ERROR":
+		return "error"
+	}
+	return ""
+}
+
+var attrRe = regexp.MustCompile(`(^|\s)[\w.\-]+=`)
+
+func attrStart(s string) int {
+	loc := attrRe.FindStringIndex(s)
+	if loc == nil {
+		return -1
+	}
+	return loc[0]
+}
+
+// logfmt splits `k=v k2="quoted v"` into key/value pairs.
+func logfmt(s string) [][2]string {
+	var out [][2]string
+	for {
+		s = strings.TrimLeft(s, " ")
+		if s == "" {
+			return out
+		}
+		eq := strings.IndexByte(s, '=')
+		sp := strings.IndexByte(s, ' ')
+		if eq < 0 || (sp >= 0 && sp < eq) {
+			if sp < 0 {
+				return out
+			}
+			s = s[sp:]
+			continue
+		}
+		key := s[:eq]
+		s = s[eq+1:]
+
+		var val string
+		if strings.HasPrefix(s, `"`) {
+			end := 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				end = len(s) - 1
+			}
+			raw := s[:end+1]
+			if err := json.Unmarshal([]byte(raw), &val); err != nil {
+				val = strings.Trim(raw, `"`)
+			}
+			s = s[end+1:]
+		} else {
+			val, s, _ = strings.Cut(s, " ")
+		}
+		out = append(out, [2]string{key, val})
+	}
+}
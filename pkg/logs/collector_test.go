@@ -0,0 +1,162 @@
+package logs_test
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/housecat-inc/cheetah/pkg/api"
+	"github.com/housecat-inc/cheetah/pkg/logs"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		_name   string
+		attrs   map[string]any
+		in      string
+		level   string
+		message string
+		ok      bool
+	}{
+		{
+			_name:   "slog json",
+			attrs:   map[string]any{"port": "8080"},
+			in:      `{"time":"2026-01-02T03:04:05Z","level":"WARN","msg":"slow start","port":"8080"}`,
+			level:   "warn",
+			message: "slow start",
+			ok:      true,
+		},
+		{
+			_name:   "slog text",
+			attrs:   map[string]any{"error": "connection refused", "n": "3"},
+			in:      `time=2026-01-02T03:04:05Z level=ERROR msg="db failed" error="connection refused" n=3`,
+			level:   "error",
+			message: "db failed",
+			ok:      true,
+		},
+		{
+			_name:   "tint with colors",
+			attrs:   map[string]any{"addr": ":8080", "app": "greet"},
+			in:      "\x1b[2m10:02AM\x1b[0m \x1b[92mINF\x1b[0m listening \x1b[2maddr=\x1b[0m:8080 \x1b[2mapp=\x1b[0mgreet",
+			level:   "info",
+			message: "listening",
+			ok:      true,
+		},
+		{
+			_name:   "std log with slog default handler",
+			in:      "2026/01/02 03:04:05 ERROR something broke",
+			level:   "error",
+			message: "something broke",
+			ok:      true,
+		},
+		{
+			_name:   "panic",
+			in:      "panic: runtime error: index out of range",
+			level:   "error",
+			message: "panic: runtime error: index out of range",
+			ok:      true,
+		},
+		{
+			_name:   "plain line",
+			in:      "hello world",
+			level:   "info",
+			message: "hello world",
+			ok:      true,
+		},
+		{
+			_name: "blank line",
+			in:    "   ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+
+			out, ok := logs.Parse(tt.in)
+			a.Equal(tt.ok, ok)
+			if !ok {
+				return
+			}
+			a.Equal(tt.level, out.Level)
+			a.Equal(tt.message, out.Message)
+			a.Equal(tt.attrs, out.Attrs)
+		})
+	}
+}
+
+func TestCollector(t *testing.T) {
+	a := assert.New(t)
+
+	var (
+		mu     sync.Mutex
+		posted []api.Log
+	)
+	var out bytes.Buffer
+	c := logs.NewCollector(&out, func(entries []api.Log) {
+		mu.Lock()
+		defer mu.Unlock()
+		posted = append(posted, entries...)
+	})
+
+	worker := c.Process("jobs")
+	c.Write([]byte("level=INFO msg=one\nlevel=WA"))
+	worker.Write([]byte("level=INFO msg=job"))
+	c.Write([]byte("RN msg=two\npartial"))
+	worker.Write([]byte(" n=1\n"))
+	web := c.Process("5000")
+	web.Write([]byte("panic: boom"))
+	a.Same(web, c.Process("5000"))
+	c.Close()
+
+	a.Equal("level=INFO msg=one\nlevel=WAlevel=INFO msg=jobRN msg=two\npartial n=1\npanic: boom", out.String())
+	a.Len(posted, 5)
+	a.Equal("one", posted[0].Message)
+	a.Equal("warn", posted[1].Level)
+	a.Equal("job", posted[2].Message)
+	a.Equal(map[string]any{"n": "1", "process": "jobs"}, posted[2].Attrs)
+	a.Equal("partial", posted[3].Message)
+	a.Equal("panic: boom", posted[4].Message)
+	a.Equal(map[string]any{"process": "5000"}, posted[4].Attrs)
+}
+
+func TestTail(t *testing.T) {
+	tests := []struct {
+		_name  string
+		n      int
+		writes []string
+		out    []string
+	}{
+		{
+			_name:  "keeps last lines",
+			n:      2,
+			writes: []string{"one\ntwo\n", "three\n"},
+			out:    []string{"two", "three"},
+		},
+		{
+			_name:  "includes partial line",
+			n:      2,
+			writes: []string{"one\ntwo\nthr", "ee"},
+			out:    []string{"two", "three"},
+		},
+		{
+			_name:  "strips colors",
+			n:      5,
+			writes: []string{"\x1b[91mpanic: boom\x1b[0m\n"},
+			out:    []string{"panic: boom"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+			tail := logs.NewTail(tt.n)
+			for _, w := range tt.writes {
+				tail.Write([]byte(w))
+			}
+			a.Equal(tt.out, tail.Lines())
+		})
+	}
+}
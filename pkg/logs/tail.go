@@ -0,0 +1,59 @@
+package logs
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Tail is an io.Writer that remembers the last n lines written to it.
+type Tail struct {
+	buf   []byte
+	lines []string
+	mu    sync.Mutex
+	n     int
+}
+
+func NewTail(n int) *Tail {
+	return &Tail{n: n}
+}
+
+func (t *Tail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.buf = append(t.buf, p...)
+	for {
+		i := bytes.IndexByte(t.buf, '\n')
+		if i < 0 {
+			break
+		}
+		t.add(string(bytes.TrimRight(t.buf[:i], "\r")))
+		t.buf = t.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (t *Tail) add(line string) {
+	line = ansiRe.ReplaceAllString(line, "")
+	t.lines = append(t.lines, line)
+	if len(t.lines) > t.n {
+		t.lines = t.lines[len(t.lines)-t.n:]
+	}
+}
+
+// Lines returns the remembered lines, oldest first, including any trailing
+// partial line.
+func (t *Tail) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]string, len(t.lines), len(t.lines)+1)
+	copy(out, t.lines)
+	if len(t.buf) > 0 {
+		out = append(out, ansiRe.ReplaceAllString(string(t.buf), ""))
+		if len(out) > t.n {
+			out = out[1:]
+		}
+	}
+	return out
+}
@@ -0,0 +1,206 @@
+package mcp
+
+// Minimal Model Context Protocol server. Speaks JSON-RPC 2.0 over
+// newline-delimited stdio or single POST requests, and only implements the
+// tools capability.
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/housecat-inc/cheetah/pkg/api"
+	"github.com/housecat-inc/cheetah/pkg/version"
+)
+
+const latestProtocolVersion = "2025-06-18"
+
+var protocolVersions = []string{latestProtocolVersion, "2025-03-26", "2024-11-05"}
+
+const (
+	codeParse          = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+type Tool struct {
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+	Name        string         `json:"name"`
+
+	call func(args json.RawMessage) (any, error)
+}
+
+type Server struct {
+	client *api.Client
+	tools  []Tool
+}
+
+func New(client *api.Client) *Server {
+	s := &Server{client: client}
+	s.tools = s.cheetahTools()
+	return s
+}
+
+type request struct {
+	ID      json.RawMessage `json:"id,omitempty"`
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+	JSONRPC string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type content struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+type callResult struct {
+	Content []content `json:"content"`
+	IsError bool      `json:"isError"`
+}
+
+// Serve reads one JSON-RPC message per line from r and writes responses to w
+// until r is closed.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	enc := json.NewEncoder(w)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if resp := s.handle(line); resp != nil {
+			if err := enc.Encode(resp); err != nil {
+				return errors.Wrap(err, "write")
+			}
+		}
+	}
+	return errors.Wrap(scanner.Err(), "read")
+}
+
+// ServeHTTP answers one JSON-RPC message per POST. The tools can run SQL
+// and change config, so only local clients are served: requests must come
+// over loopback with a JSON body and no Origin but a loopback one, which
+// keeps web pages from posting cross-origin forms to it.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if code := api.LocalStatus(r); code != 0 {
+		w.WriteHeader(code)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	resp := s.handle(body)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handle processes a single JSON-RPC message. It returns nil for
+// notifications, which get no response.
+func (s *Server) handle(msg []byte) *response {
+	var req request
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return errorResponse(json.RawMessage("null"), codeParse, "parse error")
+	}
+	if req.ID == nil {
+		return nil
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return errorResponse(req.ID, codeInvalidRequest, "invalid request")
+	}
+
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &params)
+		v := latestProtocolVersion
+		for _, pv := range protocolVersions {
+			if pv == params.ProtocolVersion {
+				v = pv
+			}
+		}
+		return result(req.ID, map[string]any{
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"instructions":    "Tools for apps running under cheetah. Each app is identified by its space name; use list_apps to find it.",
+			"protocolVersion": v,
+			"serverInfo":      map[string]any{"name": "cheetah", "version": version.Get()},
+		})
+	case "ping":
+		return result(req.ID, map[string]any{})
+	case "tools/list":
+		return result(req.ID, map[string]any{"tools": s.tools})
+	case "tools/call":
+		var params struct {
+			Arguments json.RawMessage `json:"arguments"`
+			Name      string          `json:"name"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return errorResponse(req.ID, codeInvalidParams, err.Error())
+		}
+		for _, t := range s.tools {
+			if t.Name == params.Name {
+				return result(req.ID, callTool(t, params.Arguments))
+			}
+		}
+		return errorResponse(req.ID, codeInvalidParams, "unknown tool: "+params.Name)
+	}
+
+	return errorResponse(req.ID, codeMethodNotFound, "method not found: "+req.Method)
+}
+
+func callTool(t Tool, args json.RawMessage) callResult {
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	out, err := t.call(args)
+	if err != nil {
+		return callResult{Content: []content{{Text: err.Error(), Type: "text"}}, IsError: true}
+	}
+	text, ok := out.(string)
+	if !ok {
+		bs, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return callResult{Content: []content{{Text: err.Error(), Type: "text"}}, IsError: true}
+		}
+		text = string(bs)
+	}
+	return callResult{Content: []content{{Text: text, Type: "text"}}}
+}
+
+func result(id json.RawMessage, v any) *response {
+	return &response{ID: id, JSONRPC: "2.0", Result: v}
+}
+
+func errorResponse(id json.RawMessage, code int, msg string) *response {
+	return &response{Error: &rpcError{Code: code, Message: msg}, ID: id, JSONRPC: "2.0"}
+}
@@ -0,0 +1,238 @@
+package mcp
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/housecat-inc/cheetah/pkg/api"
+)
+
+func testServer(t *testing.T) *Server {
+	srv := api.NewServer(api.ServerConfig{BluePortStart: 4000, DashboardPort: 50000, PostgresPort: 54320}, slog.Default())
+	e := echo.New()
+	srv.Routes(e)
+	ts := httptest.NewServer(e)
+	t.Cleanup(ts.Close)
+
+	client := api.NewClient(ts.URL)
+	_, err := client.AppPost(api.AppIn{Dir: "/src/greet", Space: "buffalo"})
+	require.NoError(t, err)
+	client.LogPost("buffalo", []api.Log{
+		{Level: "info", Message: "listening", Timestamp: time.Now()},
+		{Level: "error", Message: "boom", Timestamp: time.Now()},
+	})
+	client.TestPut("buffalo", api.TestRun{
+		Failed: 1,
+		ID:     "t1",
+		Status: "fail",
+		Tests:  []api.TestResult{{Output: "    app_test.go:9: boom", Package: "example.com/greet", Status: "fail", Test: "TestBoom"}},
+	})
+	return New(client)
+}
+
+func call(s *Server, id int, method string, params any) map[string]any {
+	p, _ := json.Marshal(params)
+	msg := fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":%q,"params":%s}`, id, method, p)
+	resp := s.handle([]byte(msg))
+	if resp == nil {
+		return nil
+	}
+	bs, _ := json.Marshal(resp)
+	var out map[string]any
+	json.Unmarshal(bs, &out)
+	return out
+}
+
+func toolText(resp map[string]any) (string, bool) {
+	result := resp["result"].(map[string]any)
+	text := result["content"].([]any)[0].(map[string]any)["text"].(string)
+	return text, result["isError"].(bool)
+}
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		_name  string
+		code   float64
+		method string
+		params any
+		out    string
+	}{
+		{
+			_name:  "initialize negotiates version",
+			method: "initialize",
+			params: map[string]any{"protocolVersion": "2025-03-26"},
+			out:    `"protocolVersion":"2025-03-26"`,
+		},
+		{
+			_name:  "initialize falls back to latest",
+			method: "initialize",
+			params: map[string]any{"protocolVersion": "1999-01-01"},
+			out:    `"protocolVersion":"` + latestProtocolVersion + `"`,
+		},
+		{
+			_name:  "tools list",
+			method: "tools/list",
+			out:    `"name":"tail_logs"`,
+		},
+		{
+			_name:  "unknown method",
+			code:   codeMethodNotFound,
+			method: "resources/list",
+		},
+		{
+			_name:  "unknown tool",
+			code:   codeInvalidParams,
+			method: "tools/call",
+			params: map[string]any{"name": "nope"},
+		},
+	}
+
+	s := testServer(t)
+	for i, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+			resp := call(s, i+1, tt.method, tt.params)
+			if tt.code != 0 {
+				a.Equal(tt.code, resp["error"].(map[string]any)["code"])
+				return
+			}
+			bs, _ := json.Marshal(resp["result"])
+			a.Contains(string(bs), tt.out)
+		})
+	}
+}
+
+func TestNotificationHasNoResponse(t *testing.T) {
+	s := testServer(t)
+	assert.Nil(t, s.handle([]byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)))
+}
+
+func TestTools(t *testing.T) {
+	tests := []struct {
+		_name   string
+		args    map[string]any
+		isError bool
+		name    string
+		out     string
+	}{
+		{
+			_name: "list apps",
+			name:  "list_apps",
+			out:   `"space": "buffalo"`,
+		},
+		{
+			_name: "app status",
+			args:  map[string]any{"space": "buffalo"},
+			name:  "app_status",
+			out:   `"database_url"`,
+		},
+		{
+			_name:   "app status missing space",
+			args:    map[string]any{},
+			isError: true,
+			name:    "app_status",
+			out:     "space is required",
+		},
+		{
+			_name: "tail logs filtered by level",
+			args:  map[string]any{"space": "buffalo", "level": "error"},
+			name:  "tail_logs",
+			out:   "ERROR boom",
+		},
+		{
+			_name: "set env",
+			args:  map[string]any{"space": "buffalo", "set": map[string]string{"FOO": "bar"}},
+			name:  "set_env",
+			out:   `"FOO": "bar"`,
+		},
+		{
+			_name: "rebuild",
+			args:  map[string]any{"space": "buffalo"},
+			name:  "rebuild",
+			out:   "rebuild requested for buffalo",
+		},
+		{
+			_name: "test results",
+			args:  map[string]any{"space": "buffalo"},
+			name:  "test_results",
+			out:   "FAIL example.com/greet.TestBoom",
+		},
+		{
+			_name: "run tests",
+			args:  map[string]any{"space": "buffalo", "args": []string{"-short"}},
+			name:  "run_tests",
+			out:   "queued for buffalo",
+		},
+		{
+			_name: "test results queued",
+			args:  map[string]any{"space": "buffalo"},
+			name:  "test_results",
+			out:   "is queued",
+		},
+		{
+			_name:   "rebuild unknown space",
+			args:    map[string]any{"space": "manama"},
+			isError: true,
+			name:    "rebuild",
+			out:     "404",
+		},
+	}
+
+	s := testServer(t)
+	for i, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+			resp := call(s, i+1, "tools/call", map[string]any{"name": tt.name, "arguments": tt.args})
+			text, isError := toolText(resp)
+			a.Equal(tt.isError, isError, text)
+			a.Contains(text, tt.out)
+		})
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	tests := []struct {
+		_name       string
+		contentType string
+		method      string
+		origin      string
+		remoteAddr  string
+		out         int
+	}{
+		{_name: "agent", out: http.StatusOK},
+		{_name: "localhost page", origin: "http://localhost:50000", out: http.StatusOK},
+		{_name: "charset", contentType: "application/json; charset=utf-8", out: http.StatusOK},
+		{_name: "get", method: http.MethodGet, out: http.StatusMethodNotAllowed},
+		{_name: "cross origin", origin: "https://evil.example", out: http.StatusForbidden},
+		{_name: "app space", origin: "http://buffalo.localhost:50000", out: http.StatusForbidden},
+		{_name: "remote", remoteAddr: "192.168.1.20:5555", out: http.StatusForbidden},
+		{_name: "form post", contentType: "text/plain", out: http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+
+			method := cmp.Or(tt.method, http.MethodPost)
+			req := httptest.NewRequest(method, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+			req.RemoteAddr = cmp.Or(tt.remoteAddr, "127.0.0.1:5555")
+			req.Header.Set("Content-Type", cmp.Or(tt.contentType, "application/json"))
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+			New(nil).ServeHTTP(rec, req)
+			a.Equal(tt.out, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,391 @@
+package mcp
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	_ "github.com/lib/pq"
+
+	"github.com/housecat-inc/cheetah/pkg/api"
+	"github.com/housecat-inc/cheetah/pkg/code"
+	"github.com/housecat-inc/cheetah/pkg/gotest"
+)
+
+const (
+	defaultLogLines = 50
+	defaultRowLimit = 100
+)
+
+var levels = map[string]int{"debug": 0, "info": 1, "warn": 2, "error": 3}
+
+type spaceArgs struct {
+	Space string `json:"space"`
+}
+
+func (s *Server) cheetahTools() []Tool {
+	return []Tool{
+		{
+			Name:        "list_apps",
+			Description: "List apps registered with cheetah with their health, active port and latest build status.",
+			InputSchema: schema(nil),
+			call:        s.listApps,
+		},
+		{
+			Name:        "app_status",
+			Description: "Get one app's health, ports, config providers and recent builds including compiler errors.",
+			InputSchema: schema(map[string]any{"space": spaceProp}, "space"),
+			call:        s.appStatus,
+		},
+		{
+			Name:        "tail_logs",
+			Description: "Return the most recent log lines an app printed, oldest first.",
+			InputSchema: schema(map[string]any{
+				"space": spaceProp,
+				"lines": map[string]any{"type": "integer", "description": "Number of lines to return (default 50)."},
+				"level": map[string]any{"type": "string", "enum": []string{"debug", "info", "warn", "error"}, "description": "Minimum level to include."},
+			}, "space"),
+			call: s.tailLogs,
+		},
+		{
+			Name:        "rebuild",
+			Description: "Run go generate and go build for an app, then swap to the new binary. Returns immediately; poll app_status for the result.",
+			InputSchema: schema(map[string]any{"space": spaceProp}, "space"),
+			call:        s.action(api.ActionRebuild),
+		},
+		{
+			Name:        "restart",
+			Description: "Restart an app's last successful build without rebuilding. Returns immediately; poll app_status for the result.",
+			InputSchema: schema(map[string]any{"space": spaceProp}, "space"),
+			call:        s.action(api.ActionRestart),
+		},
+		{
+			Name:        "reset_database",
+			Description: "Recreate an app's DATABASE_URL from the migrated template, discarding all data, then restart the app.",
+			InputSchema: schema(map[string]any{"space": spaceProp}, "space"),
+			call:        s.action(api.ActionReset),
+		},
+		{
+			Name:        "run_tests",
+			Description: "Run go test for an app with its env and a fresh test database. Returns immediately; call test_results for the outcome.",
+			InputSchema: schema(map[string]any{
+				"space": spaceProp,
+				"args":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "go test args, e.g. [\"-short\", \"./pkg/...\"]. Defaults to ./..."},
+			}, "space"),
+			call: s.runTests,
+		},
+		{
+			Name:        "test_results",
+			Description: "Summarize an app's latest test run, or the run with id: counts, then each failing test with its output.",
+			InputSchema: schema(map[string]any{
+				"space": spaceProp,
+				"id":    map[string]any{"type": "string", "description": "Test run ID from run_tests (default latest)."},
+			}, "space"),
+			call: s.testResults,
+		},
+		{
+			Name:        "get_env",
+			Description: "Read the config vars cheetah stores for an app. These are shared by every space of the same app.",
+			InputSchema: schema(map[string]any{"space": spaceProp}, "space"),
+			call:        s.getEnv,
+		},
+		{
+			Name:        "set_env",
+			Description: "Set or unset config vars for an app. Running spaces of the app restart with the new env.",
+			InputSchema: schema(map[string]any{
+				"space": spaceProp,
+				"set":   map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}, "description": "Vars to set."},
+				"unset": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Var names to remove."},
+			}, "space"),
+			call: s.setEnv,
+		},
+		{
+			Name:        "query",
+			Description: "Run a SQL statement against an app's DATABASE_URL and return the resulting rows.",
+			InputSchema: schema(map[string]any{
+				"space": spaceProp,
+				"sql":   map[string]any{"type": "string", "description": "SQL to run."},
+				"limit": map[string]any{"type": "integer", "description": "Maximum rows to return (default 100)."},
+			}, "space", "sql"),
+			call: s.query,
+		},
+	}
+}
+
+var spaceProp = map[string]any{"type": "string", "description": "The app's space name, e.g. little-rock."}
+
+func schema(props map[string]any, required ...string) map[string]any {
+	if props == nil {
+		props = map[string]any{}
+	}
+	out := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		out["required"] = required
+	}
+	return out
+}
+
+func decode(raw json.RawMessage, v any) error {
+	if err := json.Unmarshal(raw, v); err != nil {
+		return errors.Wrap(err, "invalid arguments")
+	}
+	return nil
+}
+
+func (s *Server) app(raw json.RawMessage) (*api.App, error) {
+	var args spaceArgs
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.Space == "" {
+		return nil, errors.New("space is required")
+	}
+	return s.client.AppGet(args.Space)
+}
+
+func (s *Server) listApps(json.RawMessage) (any, error) {
+	apps, err := s.client.AppList()
+	if err != nil {
+		return nil, err
+	}
+
+	type summary struct {
+		Build  string `json:"build,omitempty"`
+		Dir    string `json:"dir"`
+		Health string `json:"health"`
+		Port   int    `json:"port"`
+		Space  string `json:"space"`
+		URL    string `json:"url"`
+	}
+	out := make([]summary, 0, len(apps))
+	for _, a := range apps {
+		sum := summary{
+			Dir:    a.Dir,
+			Health: a.Health.Status,
+			Port:   a.Ports.Active,
+			Space:  a.Space,
+			URL:    appURL(s.client.URL, a.Space),
+		}
+		if len(a.Builds) > 0 {
+			sum.Build = a.Builds[len(a.Builds)-1].Status
+		}
+		out = append(out, sum)
+	}
+	return out, nil
+}
+
+func (s *Server) appStatus(raw json.RawMessage) (any, error) {
+	app, err := s.app(raw)
+	if err != nil {
+		return nil, err
+	}
+	app.Logs = nil
+	app.Tests = nil
+	return struct {
+		*api.App
+		URL string `json:"url"`
+	}{app, appURL(s.client.URL, app.Space)}, nil
+}
+
+func (s *Server) tailLogs(raw json.RawMessage) (any, error) {
+	var args struct {
+		Level string `json:"level"`
+		Lines int    `json:"lines"`
+		Space string `json:"space"`
+	}
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	app, err := s.app(raw)
+	if err != nil {
+		return nil, err
+	}
+	if args.Lines <= 0 {
+		args.Lines = defaultLogLines
+	}
+
+	var lines []string
+	for _, l := range app.Logs {
+		if levels[l.Level] < levels[args.Level] {
+			continue
+		}
+		line := fmt.Sprintf("%s %s %s", l.Timestamp.Format("15:04:05"), strings.ToUpper(l.Level), l.Message)
+		for k, v := range l.Attrs {
+			line += fmt.Sprintf(" %s=%v", k, v)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) > args.Lines {
+		lines = lines[len(lines)-args.Lines:]
+	}
+	if len(lines) == 0 {
+		return "no logs", nil
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (s *Server) action(action string) func(json.RawMessage) (any, error) {
+	return func(raw json.RawMessage) (any, error) {
+		app, err := s.app(raw)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.client.Action(app.Space, action); err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("%s requested for %s", action, app.Space), nil
+	}
+}
+
+func (s *Server) runTests(raw json.RawMessage) (any, error) {
+	var args struct {
+		Args []string `json:"args"`
+	}
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	app, err := s.app(raw)
+	if err != nil {
+		return nil, err
+	}
+	run, err := s.client.TestStart(app.Space, args.Args)
+	if err != nil {
+		return nil, err
+	}
+	return fmt.Sprintf("test run %s queued for %s", run.ID, app.Space), nil
+}
+
+func (s *Server) testResults(raw json.RawMessage) (any, error) {
+	var args struct {
+		ID string `json:"id"`
+	}
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	app, err := s.app(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(app.Tests) == 0 {
+		return "no test runs", nil
+	}
+
+	run := app.Tests[len(app.Tests)-1]
+	if args.ID != "" {
+		if run, err = s.client.TestGet(app.Space, args.ID); err != nil {
+			return nil, err
+		}
+	}
+	if run.Status == "queued" || run.Status == "running" {
+		return fmt.Sprintf("test run %s is %s", run.ID, run.Status), nil
+	}
+	return gotest.Summary(run), nil
+}
+
+func (s *Server) getEnv(raw json.RawMessage) (any, error) {
+	app, err := s.app(raw)
+	if err != nil {
+		return nil, err
+	}
+	return s.client.EnvGet(code.AppName(app.Dir, app.Space))
+}
+
+func (s *Server) setEnv(raw json.RawMessage) (any, error) {
+	var args struct {
+		Set   map[string]string `json:"set"`
+		Unset []string          `json:"unset"`
+	}
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	app, err := s.app(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	name := code.AppName(app.Dir, app.Space)
+	vars, err := s.client.EnvGet(name)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range args.Set {
+		vars[k] = v
+	}
+	for _, k := range args.Unset {
+		delete(vars, k)
+	}
+	if err := s.client.EnvPut(name, vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+func (s *Server) query(raw json.RawMessage) (any, error) {
+	var args struct {
+		Limit int    `json:"limit"`
+		SQL   string `json:"sql"`
+	}
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(args.SQL) == "" {
+		return nil, errors.New("sql is required")
+	}
+	app, err := s.app(raw)
+	if err != nil {
+		return nil, err
+	}
+	if args.Limit <= 0 {
+		args.Limit = defaultRowLimit
+	}
+
+	db, err := sql.Open("postgres", app.DatabaseURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "connect")
+	}
+	defer db.Close()
+
+	rows, err := db.Query(args.SQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, errors.Wrap(err, "columns")
+	}
+
+	out := struct {
+		Columns   []string `json:"columns"`
+		Rows      [][]any  `json:"rows"`
+		Truncated bool     `json:"truncated,omitempty"`
+	}{Columns: cols, Rows: [][]any{}}
+	for rows.Next() {
+		if len(out.Rows) == args.Limit {
+			out.Truncated = true
+			break
+		}
+		vals := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, errors.Wrap(err, "scan")
+		}
+		for i, v := range vals {
+			if b, ok := v.([]byte); ok {
+				vals[i] = string(b)
+			}
+		}
+		out.Rows = append(out.Rows, vals)
+	}
+	return out, errors.Wrap(rows.Err(), "rows")
+}
+
+func appURL(cheetahURL, space string) string {
+	return strings.Replace(cheetahURL, "://localhost", "://"+space+".localhost", 1) + "/"
+}
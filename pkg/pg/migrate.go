@@ -1,6 +1,7 @@
 package pg
 
 import (
+	"cmp"
 	"crypto/rand"
 	"crypto/sha256"
 	"database/sql"
@@ -273,6 +274,10 @@ func quoteIdent(s string) string {
 	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
 }
 
+func quoteLiteral(s string) string {
+	return `'` + strings.ReplaceAll(s, `'`, `''`) + `'`
+}
+
 type sqlcConfig struct {
 	SQL []struct {
 		Schema interface{} `yaml:"schema"`
@@ -400,6 +405,188 @@ func CreateTestDB(templateURL string) (string, func(), error) {
 	return dbURL, cleanup, nil
 }
 
+// Seed returns the URL of database name, a copy of the template with the
+// SQL files run in order. It is made the first time and reused after, and
+// made again once the template or the files change.
+func Seed(templateURL, name string, files []string) (string, error) {
+	hash, err := Hash(files)
+	if err != nil {
+		return "", errors.Wrap(err, "hash seeds")
+	}
+	version, err := Version(templateURL)
+	if err != nil {
+		return "", errors.Wrap(err, "template version")
+	}
+
+	return Clone(templateURL, name, version+"_s"+hash, func(db *sql.DB) error {
+		for _, f := range files {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "read %s", f)
+			}
+			if _, err := db.Exec(string(data)); err != nil {
+				return errors.Wrapf(err, "seed %s", f)
+			}
+		}
+		return nil
+	})
+}
+
+// Version is the version Clone recorded on a database, or its name for one
+// Clone didn't make, like a migrated template.
+func Version(dbURL string) (string, error) {
+	adminURL, err := AdminURL(dbURL)
+	if err != nil {
+		return "", errors.Wrap(err, "admin url")
+	}
+	name, err := DBName(dbURL)
+	if err != nil {
+		return "", errors.Wrap(err, "db name")
+	}
+
+	adminDB, err := sql.Open("postgres", adminURL)
+	if err != nil {
+		return "", errors.Wrap(err, "connect to admin db")
+	}
+	defer adminDB.Close()
+
+	version, _, err := dbVersion(adminDB, name)
+	if err != nil {
+		return "", err
+	}
+	return cmp.Or(version, name), nil
+}
+
+// dbVersion returns the version recorded on database name and whether it
+// exists.
+func dbVersion(adminDB *sql.DB, name string) (string, bool, error) {
+	var version sql.NullString
+	err := adminDB.QueryRow("SELECT shobj_description(oid, 'pg_database') FROM pg_database WHERE datname = $1", name).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, errors.Wrap(err, "check db")
+	}
+	return version.String, true, nil
+}
+
+// Clone returns the URL of database name, creating it from the template
+// and running setup on it unless it exists at version. One left from
+// another version is dropped first. The copy is set up under a temporary
+// name and renamed when done, so concurrent test processes never see it
+// half seeded.
+func Clone(templateURL, name, version string, setup func(*sql.DB) error) (string, error) {
+	adminURL, err := AdminURL(templateURL)
+	if err != nil {
+		return "", errors.Wrap(err, "admin url")
+	}
+	tmplName, err := DBName(templateURL)
+	if err != nil {
+		return "", errors.Wrap(err, "template name")
+	}
+	dbURL, err := replaceDBName(templateURL, name)
+	if err != nil {
+		return "", errors.Wrap(err, "build url")
+	}
+
+	adminDB, err := sql.Open("postgres", adminURL)
+	if err != nil {
+		return "", errors.Wrap(err, "connect to admin db")
+	}
+	defer adminDB.Close()
+
+	current, exists, err := dbVersion(adminDB, name)
+	if err != nil {
+		return "", err
+	}
+	if exists && current == version {
+		return dbURL, nil
+	}
+	if exists {
+		slog.Info("dropping stale db", "name", name, "version", current)
+		dropDB(adminDB, name)
+	}
+
+	b := make([]byte, 4)
+	rand.Read(b)
+	tmpName := fmt.Sprintf("%s_%x", name, b)
+	if err := Create(adminURL, tmplName, tmpName); err != nil {
+		return "", errors.Wrap(err, "clone db")
+	}
+	if _, err := adminDB.Exec(fmt.Sprintf("COMMENT ON DATABASE %s IS %s", quoteIdent(tmpName), quoteLiteral(version))); err != nil {
+		dropDB(adminDB, tmpName)
+		return "", errors.Wrap(err, "record version")
+	}
+
+	if setup != nil {
+		tmpURL, err := replaceDBName(templateURL, tmpName)
+		if err != nil {
+			dropDB(adminDB, tmpName)
+			return "", errors.Wrap(err, "build url")
+		}
+		db, err := sql.Open("postgres", tmpURL)
+		if err != nil {
+			dropDB(adminDB, tmpName)
+			return "", errors.Wrap(err, "connect to db")
+		}
+		err = setup(db)
+		db.Close()
+		if err != nil {
+			dropDB(adminDB, tmpName)
+			return "", err
+		}
+	}
+
+	if _, err := adminDB.Exec(fmt.Sprintf("ALTER DATABASE %s RENAME TO %s", quoteIdent(tmpName), quoteIdent(name))); err != nil {
+		dropDB(adminDB, tmpName)
+		if current, exists, err := dbVersion(adminDB, name); err == nil && exists && current == version {
+			return dbURL, nil
+		}
+		return "", errors.Wrap(err, "rename db")
+	}
+	return dbURL, nil
+}
+
+// CreateSchema creates schema in the database if needed and returns a URL
+// that puts it first on the search_path, ahead of public.
+func CreateSchema(databaseURL, schema string) (string, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return "", errors.Wrap(err, "connect")
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", quoteIdent(schema))); err != nil {
+		return "", errors.Wrap(err, "create schema")
+	}
+
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		return "", errors.Wrap(err, "parse url")
+	}
+	q := u.Query()
+	q.Set("search_path", searchPath(schema))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// CreateSchemaTx creates schema in tx if needed and puts it first on the
+// search_path until tx ends.
+func CreateSchemaTx(tx *sql.Tx, schema string) error {
+	if _, err := tx.Exec(fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", quoteIdent(schema))); err != nil {
+		return errors.Wrap(err, "create schema")
+	}
+	if _, err := tx.Exec("SELECT set_config('search_path', $1, true)", searchPath(schema)); err != nil {
+		return errors.Wrap(err, "set search path")
+	}
+	return nil
+}
+
+func searchPath(schema string) string {
+	return quoteIdent(schema) + ",public"
+}
+
 func Drop(databaseURL string) error {
 	adminURL, err := AdminURL(databaseURL)
 	if err != nil {
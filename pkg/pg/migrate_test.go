@@ -486,3 +486,61 @@ func dropTestDB(pgURL, name string) {
 	db.Exec("SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = $1 AND pid <> pg_backend_pid()", name)
 	db.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s", quoteIdent(name)))
 }
+
+func TestSeed(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	dir := t.TempDir()
+	migDir := filepath.Join(dir, "migrations")
+	r.NoError(os.MkdirAll(migDir, 0o755))
+	r.NoError(os.WriteFile(filepath.Join(migDir, "001_items.sql"), []byte("-- +goose Up\nCREATE TABLE items (id serial PRIMARY KEY, name text NOT NULL);\n-- +goose Down\nDROP TABLE items;\n"), 0o644))
+	seeds := []string{filepath.Join(dir, "a.sql"), filepath.Join(dir, "b.sql")}
+	r.NoError(os.WriteFile(seeds[0], []byte("INSERT INTO items (name) VALUES ('apple');\n"), 0o644))
+	r.NoError(os.WriteFile(seeds[1], []byte("INSERT INTO items (name) VALUES ('banana');\nINSERT INTO items (name) VALUES ('cherry');\n"), 0o644))
+
+	hash, err := Hash([]string{migDir})
+	r.NoError(err)
+	tmplName, err := Template(adminURL, []string{migDir}, hash)
+	r.NoError(err)
+	tmplURL, err := replaceDBName(adminURL, tmplName)
+	r.NoError(err)
+
+	seededName := "seed_" + hash
+	t.Cleanup(func() { dropTestDB(adminURL, seededName) })
+	r.NoError(os.WriteFile(seeds[0], []byte("INSERT INTO items (name) VALUES ('stale');\n"), 0o644))
+	staleURL, err := Seed(tmplURL, seededName, seeds)
+	r.NoError(err)
+	staleVersion, err := Version(staleURL)
+	r.NoError(err)
+
+	r.NoError(os.WriteFile(seeds[0], []byte("INSERT INTO items (name) VALUES ('apple');\n"), 0o644))
+	seededURL, err := Seed(tmplURL, seededName, seeds)
+	r.NoError(err)
+	a.Equal(staleURL, seededURL)
+	version, err := Version(seededURL)
+	r.NoError(err)
+	a.NotEqual(staleVersion, version)
+
+	again, err := Seed(tmplURL, seededName, seeds)
+	r.NoError(err)
+	a.Equal(seededURL, again)
+
+	testURL, cleanup, err := CreateTestDB(seededURL)
+	r.NoError(err)
+	t.Cleanup(cleanup)
+
+	testURL, err = CreateSchema(testURL, "tenant_a")
+	r.NoError(err)
+	db, err := sql.Open("postgres", testURL)
+	r.NoError(err)
+	defer db.Close()
+
+	var n int
+	r.NoError(db.QueryRow("SELECT count(*) FROM items").Scan(&n))
+	a.Equal(3, n)
+
+	var schema string
+	r.NoError(db.QueryRow("SELECT current_schema()").Scan(&schema))
+	a.Equal("tenant_a", schema)
+}
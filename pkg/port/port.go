@@ -13,39 +13,70 @@ type Config struct {
 	CheckHealth   func(port int) (int, error)
 	CheckInterval time.Duration
 	CheckRetries  int
+	Drain         func(port int, grace time.Duration)
+	DrainGrace    time.Duration
 	ReportHealth  func(status string, port int)
 }
 
 type Manager struct {
-	active int
-	blue   int
-	config Config
-	green  int
-	mu     sync.Mutex
+	active   int
+	blue     int
+	config   Config
+	draining map[int]chan struct{}
+	green    int
+	mu       sync.Mutex
 }
 
 func New(blue, green int, cfg Config) *Manager {
 	return &Manager{
-		active: blue,
-		blue:   blue,
-		config: cfg,
-		green:  green,
+		active:   blue,
+		blue:     blue,
+		config:   cfg,
+		draining: map[int]chan struct{}{},
+		green:    green,
 	}
 }
 
-func DefaultConfig(client *api.Client, space string) Config {
+// DefaultConfig waits for the probe's ReadyPath, falling back to Path, to
+// return 200. Zero probe fields default to GET /health with a 1s timeout,
+// polled every 500ms for up to 30 tries.
+func DefaultConfig(client *api.Client, space string, probe api.Probe) Config {
+	path := "/health"
+	if probe.Path != "" {
+		path = probe.Path
+	}
+	if probe.ReadyPath != "" {
+		path = probe.ReadyPath
+	}
+	timeout := 1 * time.Second
+	if probe.Timeout > 0 {
+		timeout = probe.Timeout
+	}
+	interval := 500 * time.Millisecond
+	if probe.Interval > 0 {
+		interval = probe.Interval
+	}
+	retries := 30
+	if probe.Retries > 0 {
+		retries = probe.Retries
+	}
+
 	return Config{
 		CheckHealth: func(port int) (int, error) {
-			c := &http.Client{Timeout: 1 * time.Second}
-			resp, err := c.Get(fmt.Sprintf("http://localhost:%d/health", port))
+			c := &http.Client{Timeout: timeout}
+			resp, err := c.Get(fmt.Sprintf("http://localhost:%d%s", port, path))
 			if err != nil {
 				return 0, err
 			}
 			resp.Body.Close()
 			return resp.StatusCode, nil
 		},
-		CheckInterval: 500 * time.Millisecond,
-		CheckRetries:  30,
+		CheckInterval: interval,
+		CheckRetries:  retries,
+		Drain: func(port int, grace time.Duration) {
+			client.Drain(space, port, grace)
+		},
+		DrainGrace: 10 * time.Second,
 		ReportHealth: func(status string, port int) {
 			client.HealthUpdate(space, port, status)
 		},
@@ -91,9 +122,13 @@ func (m *Manager) ReportHealth(status string) {
 	m.config.ReportHealth(status, port)
 }
 
+// Swap starts the inactive port and makes it active once healthy. The old
+// port drains and stops in the background so long-lived requests don't hold
+// up the caller; a later swap back onto it waits for that to finish.
 func (m *Manager) Swap(start func(int) error, stop func(int)) bool {
 	inactive := m.Inactive()
 	old := m.Active()
+	m.Drained(inactive)
 
 	if err := start(inactive); err != nil {
 		return false
@@ -104,8 +139,30 @@ func (m *Manager) Swap(start func(int) error, stop func(int)) bool {
 		return false
 	}
 
-	m.SetActive(inactive)
+	done := make(chan struct{})
+	m.mu.Lock()
+	m.active = inactive
+	m.draining[old] = done
+	m.mu.Unlock()
 	m.ReportHealth("healthy")
-	stop(old)
+
+	go func() {
+		defer close(done)
+		if m.config.Drain != nil {
+			m.config.Drain(old, m.config.DrainGrace)
+		}
+		stop(old)
+	}()
 	return true
 }
+
+// Drained waits for the process swapped out of port to finish draining
+// and stop.
+func (m *Manager) Drained(port int) {
+	m.mu.Lock()
+	done := m.draining[port]
+	m.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
@@ -2,12 +2,17 @@ package port
 
 import (
 	"fmt"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/cockroachdb/errors"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/housecat-inc/cheetah/pkg/api"
 )
 
 func testConfig(checkHealth func(int) (int, error), reports *[]string) Config {
@@ -90,6 +95,7 @@ func TestSwap(t *testing.T) {
 		out        bool
 		startErr   error
 		wantActive int
+		wantDrain  []int
 		wantReport []string
 		wantStop   []int
 	}{
@@ -98,6 +104,7 @@ func TestSwap(t *testing.T) {
 			healthy:    true,
 			out:        true,
 			wantActive: 5001,
+			wantDrain:  []int{5000},
 			wantReport: []string{"healthy:5001"},
 			wantStop:   []int{5000},
 		},
@@ -126,17 +133,23 @@ func TestSwap(t *testing.T) {
 				}
 				return 0, errors.New("unhealthy")
 			}
+			var drained, stopped []int
 			cfg := testConfig(checkHealth, &reports)
+			cfg.Drain = func(port int, grace time.Duration) { drained = append(drained, port) }
 			m := New(5000, 5001, cfg)
 
-			var stopped []int
 			start := func(port int) error { return tt.startErr }
-			stop := func(port int) { stopped = append(stopped, port) }
+			stop := func(port int) {
+				a.Equal(tt.wantDrain, drained, "drain before stop")
+				stopped = append(stopped, port)
+			}
 
 			result := m.Swap(start, stop)
 			a.Equal(tt.out, result)
 			a.Equal(tt.wantActive, m.Active())
+			m.Drained(5000)
 
+			a.Equal(tt.wantDrain, drained)
 			if tt.wantReport != nil {
 				a.Equal(tt.wantReport, reports)
 			}
@@ -147,6 +160,40 @@ func TestSwap(t *testing.T) {
 	}
 }
 
+func TestSwapDrainsInBackground(t *testing.T) {
+	a := assert.New(t)
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var events []string
+	record := func(e string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}
+
+	cfg := testConfig(func(int) (int, error) { return http.StatusOK, nil }, nil)
+	cfg.Drain = func(port int, grace time.Duration) { <-release }
+	m := New(5000, 5001, cfg)
+
+	start := func(port int) error { record(fmt.Sprintf("start:%d", port)); return nil }
+	stop := func(port int) { record(fmt.Sprintf("stop:%d", port)) }
+	a.True(m.Swap(start, stop))
+	a.Equal(5001, m.Active())
+
+	swapped := make(chan bool)
+	go func() { swapped <- m.Swap(start, stop) }()
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	a.Equal([]string{"start:5001"}, events, "second swap waits for 5000 to drain")
+	mu.Unlock()
+
+	close(release)
+	a.True(<-swapped)
+	m.Drained(5001)
+	a.Equal([]string{"start:5001", "stop:5000", "start:5000", "stop:5001"}, events)
+}
+
 func TestReportHealth(t *testing.T) {
 	a := assert.New(t)
 	var reports []string
@@ -159,3 +206,48 @@ func TestReportHealth(t *testing.T) {
 
 	a.Equal([]string{"unknown:5000", "healthy:5001"}, reports)
 }
+
+func TestDefaultConfig(t *testing.T) {
+	tests := []struct {
+		_name   string
+		probe   api.Probe
+		out     string
+		retries int
+	}{
+		{
+			_name:   "defaults",
+			out:     "/health",
+			retries: 30,
+		},
+		{
+			_name:   "health path",
+			probe:   api.Probe{Path: "/healthz"},
+			out:     "/healthz",
+			retries: 30,
+		},
+		{
+			_name:   "ready path wins",
+			probe:   api.Probe{Path: "/healthz", ReadyPath: "/readyz", Retries: 60},
+			out:     "/readyz",
+			retries: 60,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+
+			var path string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+			}))
+			defer srv.Close()
+
+			cfg := DefaultConfig(nil, "buffalo", tt.probe)
+			status, err := cfg.CheckHealth(srv.Listener.Addr().(*net.TCPAddr).Port)
+			a.NoError(err)
+			a.Equal(http.StatusOK, status)
+			a.Equal(tt.out, path)
+			a.Equal(tt.retries, cfg.CheckRetries)
+		})
+	}
+}
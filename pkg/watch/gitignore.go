@@ -0,0 +1,197 @@
+package watch
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is one line of a .gitignore file or .git/info/exclude.
+type ignoreRule struct {
+	anchored bool
+	base     string
+	dirOnly  bool
+	negate   bool
+	pattern  string
+}
+
+func (r ignoreRule) match(abs string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	rel, err := filepath.Rel(r.base, abs)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	if !r.anchored {
+		rel = path.Base(rel)
+	}
+	return matchGlob(r.pattern, rel)
+}
+
+// parseIgnore reads gitignore rules from file, relative to base.
+func parseIgnore(file, base string) []ignoreRule {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasSuffix(line, `\ `) {
+			line = strings.TrimRight(line[:len(line)-2], " ") + " "
+		} else {
+			line = strings.TrimRight(line, " \t\r")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		r := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			r.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		r.pattern = line
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// gitRoot finds the repository that contains dir and the path of its
+// info/exclude file. Worktrees share the exclude file of the main repo.
+func gitRoot(dir string) (string, string) {
+	for d := dir; ; d = filepath.Dir(d) {
+		git := filepath.Join(d, ".git")
+		info, err := os.Stat(git)
+		if err == nil {
+			if info.IsDir() {
+				return d, filepath.Join(git, "info", "exclude")
+			}
+			return d, worktreeExclude(git)
+		}
+		if filepath.Dir(d) == d {
+			return dir, ""
+		}
+	}
+}
+
+func worktreeExclude(gitFile string) string {
+	data, err := os.ReadFile(gitFile)
+	if err != nil {
+		return ""
+	}
+	gitDir := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(data)), "gitdir:"))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(gitFile), gitDir)
+	}
+	if common, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		c := strings.TrimSpace(string(common))
+		if !filepath.IsAbs(c) {
+			c = filepath.Join(gitDir, c)
+		}
+		gitDir = c
+	}
+	return filepath.Join(gitDir, "info", "exclude")
+}
+
+// gitIgnored reports whether git would ignore path. Rules from
+// .git/info/exclude come first, then each .gitignore from the repo root
+// down, and the last matching rule wins. A path is also ignored when any
+// directory between it and the watched dir is.
+func (w *Watcher) gitIgnored(path string, isDir bool) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	root, err := filepath.Abs(w.dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+
+	w.ignoreMu.Lock()
+	defer w.ignoreMu.Unlock()
+
+	if w.repoRoot == "" {
+		w.repoRoot, w.excludeFile = gitRoot(root)
+	}
+
+	var rules []ignoreRule
+	if w.excludeFile != "" {
+		rules = append(rules, w.rulesLocked(w.excludeFile, w.repoRoot)...)
+	}
+	dirs := []string{root}
+	for d := root; d != w.repoRoot && filepath.Dir(d) != d; {
+		d = filepath.Dir(d)
+		dirs = append(dirs, d)
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rules = append(rules, w.rulesLocked(filepath.Join(dirs[i], ".gitignore"), dirs[i])...)
+	}
+
+	dir := root
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i, part := range parts {
+		dir = filepath.Join(dir, part)
+		last := i == len(parts)-1
+		if ignoredBy(rules, dir, isDir || !last) {
+			return true
+		}
+		if !last {
+			rules = append(rules, w.rulesLocked(filepath.Join(dir, ".gitignore"), dir)...)
+		}
+	}
+	return false
+}
+
+func ignoredBy(rules []ignoreRule, abs string, isDir bool) bool {
+	ignored := false
+	for _, r := range rules {
+		if r.match(abs, isDir) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+func (w *Watcher) rulesLocked(file, base string) []ignoreRule {
+	if w.ignoreRules == nil {
+		w.ignoreRules = map[string][]ignoreRule{}
+	}
+	rules, ok := w.ignoreRules[file]
+	if !ok {
+		rules = parseIgnore(file, base)
+		w.ignoreRules[file] = rules
+	}
+	return rules
+}
+
+// resetIgnores drops cached rules so edited ignore files take effect.
+func (w *Watcher) resetIgnores() {
+	w.ignoreMu.Lock()
+	defer w.ignoreMu.Unlock()
+	w.ignoreRules = nil
+}
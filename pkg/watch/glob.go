@@ -0,0 +1,73 @@
+package watch
+
+import (
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// matchGlob matches a slash separated path against a pattern where "**"
+// matches any number of directories.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				return len(parts) > 0
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// matchesDir reports whether patterns ignore the directory dir as a whole,
+// where "dir/**" counts for dir itself. A directory a negated pattern may
+// reach into isn't ignored, so its files can still be let back in.
+func matchesDir(dir string, patterns []string) bool {
+	dir = filepath.ToSlash(dir)
+	parts := strings.Split(dir, "/")
+	withDirs := make([]string, 0, 2*len(patterns))
+	for _, p := range patterns {
+		withDirs = append(withDirs, p)
+		if prefix, ok := strings.CutSuffix(p, "/**"); ok {
+			withDirs = append(withDirs, prefix)
+		}
+	}
+	if !MatchesAny(dir, withDirs) {
+		return false
+	}
+	for _, p := range patterns {
+		p, ok := strings.CutPrefix(p, "!")
+		if !ok {
+			continue
+		}
+		segs := strings.Split(strings.TrimPrefix(p, "/"), "/")
+		if len(segs) == 1 {
+			return false
+		}
+		if slices.Contains(segs[:len(segs)-1], "**") {
+			return false
+		}
+		if len(segs) > len(parts) && matchSegments(segs[:len(parts)], parts) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,207 @@
+package watch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// notifier is the inotify backend. inotify is not recursive, so every
+// directory gets its own watch and new directories are added as they appear.
+type notifier struct {
+	fs   *fsnotify.Watcher
+	once sync.Once
+}
+
+func (n *notifier) close() {
+	n.once.Do(func() { n.fs.Close() })
+}
+
+func (w *Watcher) startNotify() error {
+	fs, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	n := &notifier{fs: fs}
+	if err := w.addDirs(n, w.dir); err != nil {
+		n.close()
+		return err
+	}
+
+	w.mutex.Lock()
+	w.notify = n
+	w.mutex.Unlock()
+
+	w.stopWaitGroup.Add(1)
+	go func() {
+		defer w.stopWaitGroup.Done()
+		for {
+			select {
+			case ev, ok := <-fs.Events:
+				if !ok {
+					return
+				}
+				if err := w.handleEvent(n, ev); err != nil {
+					w.fallback(n, err)
+					return
+				}
+			case err, ok := <-fs.Errors:
+				if !ok {
+					return
+				}
+				if errors.Is(err, fsnotify.ErrEventOverflow) {
+					w.fallback(n, err)
+					return
+				}
+				w.logger.Warn("watch", "error", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// fallback swaps a failing notifier for the poller, e.g. after a new
+// directory hits the inotify watch limit.
+func (w *Watcher) fallback(n *notifier, err error) {
+	w.logger.Warn("watch falling back to polling", "error", err)
+	n.close()
+
+	w.mutex.Lock()
+	w.notify = nil
+	w.active = BackendPoll
+	w.mutex.Unlock()
+
+	w.RefreshFileList()
+	if !w.stopping() {
+		w.startPoll()
+	}
+}
+
+func (w *Watcher) addDirs(n *notifier, root string) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if w.skipDir(path) {
+			return filepath.SkipDir
+		}
+		if err := n.fs.Add(path); err != nil {
+			if isWatchLimit(err) {
+				return err
+			}
+			w.logger.Warn("watch", "dir", path, "error", err)
+		}
+		return nil
+	})
+}
+
+func (w *Watcher) handleEvent(n *notifier, ev fsnotify.Event) error {
+	path := ev.Name
+	if filepath.Base(path) == ".gitignore" {
+		w.resetIgnores()
+	}
+
+	switch {
+	case ev.Has(fsnotify.Create):
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if w.skipDir(path) {
+				return nil
+			}
+			if err := w.addDirs(n, path); err != nil {
+				return err
+			}
+			for p, mod := range w.scan(path) {
+				w.changed(p, mod)
+			}
+			return nil
+		}
+		w.wrote(path, info)
+
+	case ev.Has(fsnotify.Write):
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil
+		}
+		// An untracked file written empty already existed and was
+		// skipped, so wait for the contents before checking it again.
+		if w.tracked(path) || info.Size() > 0 {
+			w.wrote(path, info)
+		}
+
+	case ev.Has(fsnotify.Remove), ev.Has(fsnotify.Rename):
+		w.removed(path)
+	}
+	return nil
+}
+
+// wrote tracks a created or written file, even an empty one. A file that
+// turns out to be generated once written is dropped again, which cancels
+// out within a batch.
+func (w *Watcher) wrote(path string, info os.FileInfo) {
+	switch {
+	case w.wants(path):
+		w.changed(path, info.ModTime())
+	case w.tracked(path):
+		w.removed(path)
+	}
+}
+
+func (w *Watcher) tracked(path string) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	_, ok := w.modTimes[path]
+	return ok
+}
+
+// changed records a new mod time for path and reports it, skipping
+// duplicate events for the same write.
+func (w *Watcher) changed(path string, mod time.Time) {
+	w.mutex.Lock()
+	prev, ok := w.modTimes[path]
+	if ok && prev.Equal(mod) {
+		w.mutex.Unlock()
+		return
+	}
+	w.modTimes[path] = mod
+	w.mutex.Unlock()
+
+	op := OpModified
+	if !ok {
+		op = OpAdded
+	}
+	w.emit(Change{Op: op, Path: path})
+}
+
+// removed forgets path, or every file under it if it was a directory.
+func (w *Watcher) removed(path string) {
+	w.mutex.Lock()
+	var changes []Change
+	for p := range w.modTimes {
+		if p == path || strings.HasPrefix(p, path+string(filepath.Separator)) {
+			delete(w.modTimes, p)
+			changes = append(changes, Change{Op: OpDeleted, Path: p})
+		}
+	}
+	w.mutex.Unlock()
+
+	w.emit(changes...)
+}
+
+func (w *Watcher) stopping() bool {
+	return atomic.LoadInt32(&w.shouldStop) != 0
+}
+
+func isWatchLimit(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EMFILE)
+}
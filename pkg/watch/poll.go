@@ -0,0 +1,114 @@
+package watch
+
+// Polling backend inspired by esbuild's watcher.
+// Uses randomized scan order with a recent-items fast path to balance
+// CPU usage against change detection latency.
+
+import (
+	"math/rand"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	watchIntervalSleep       = 100 * time.Millisecond
+	maxRecentItemCount       = 16
+	minItemCountPerIter      = 64
+	maxIntervalsBeforeUpdate = 20
+	rescanInterval           = 50 // ~5 seconds between full rescans
+)
+
+func (w *Watcher) startPoll() {
+	w.stopWaitGroup.Add(1)
+	go func() {
+		defer w.stopWaitGroup.Done()
+		rescanCounter := 0
+		for atomic.LoadInt32(&w.shouldStop) == 0 {
+			time.Sleep(watchIntervalSleep)
+
+			rescanCounter++
+			if rescanCounter >= rescanInterval {
+				w.RefreshFileList()
+				rescanCounter = 0
+			}
+
+			w.emit(w.tryToFindDirtyPaths()...)
+		}
+	}()
+}
+
+func (w *Watcher) tryToFindDirtyPaths() []Change {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// Rebuild scan list if empty
+	if len(w.itemsToScan) == 0 {
+		items := w.itemsToScan[:0]
+		for path := range w.modTimes {
+			items = append(items, path)
+		}
+		rand.Shuffle(len(items), func(i, j int) {
+			items[i], items[j] = items[j], items[i]
+		})
+		w.itemsToScan = items
+
+		perIter := (len(items) + maxIntervalsBeforeUpdate - 1) / maxIntervalsBeforeUpdate
+		if perIter < minItemCountPerIter {
+			perIter = minItemCountPerIter
+		}
+		w.itemsPerIteration = perIter
+	}
+
+	var changes []Change
+	seen := map[string]bool{}
+
+	// Always check recent items first
+	for _, path := range w.recentItems {
+		if op, dirty := w.isDirty(path); dirty {
+			changes = append(changes, Change{Op: op, Path: path})
+			seen[path] = true
+		}
+	}
+
+	// Check a batch from the scan list
+	remainingCount := len(w.itemsToScan) - w.itemsPerIteration
+	if remainingCount < 0 {
+		remainingCount = 0
+	}
+	toCheck := w.itemsToScan[remainingCount:]
+	w.itemsToScan = w.itemsToScan[:remainingCount]
+
+	for _, path := range toCheck {
+		if seen[path] {
+			continue
+		}
+		if op, dirty := w.isDirty(path); dirty {
+			changes = append(changes, Change{Op: op, Path: path})
+			w.recentItems = append(w.recentItems, path)
+			if len(w.recentItems) > maxRecentItemCount {
+				copy(w.recentItems, w.recentItems[1:])
+				w.recentItems = w.recentItems[:maxRecentItemCount]
+			}
+		}
+	}
+
+	return changes
+}
+
+func (w *Watcher) isDirty(path string) (Op, bool) {
+	if _, ok := w.modTimes[path]; !ok {
+		return "", false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		// File was deleted
+		delete(w.modTimes, path)
+		return OpDeleted, true
+	}
+	if info.ModTime().After(w.modTimes[path]) {
+		w.modTimes[path] = info.ModTime()
+		return OpModified, true
+	}
+	return "", false
+}
@@ -13,6 +13,8 @@ import (
 	"github.com/housecat-inc/cheetah/pkg/watch"
 )
 
+var backends = []watch.Backend{watch.BackendNotify, watch.BackendPoll}
+
 func TestMatchesAny(t *testing.T) {
 	tests := []struct {
 		_name    string
@@ -56,6 +58,42 @@ func TestMatchesAny(t *testing.T) {
 			patterns: []string{"*.css", "*.js"},
 			out:      true,
 		},
+		{
+			_name:    "double star",
+			path:     "pkg/db/migrations/001.sql",
+			patterns: []string{"pkg/**/*.sql"},
+			out:      true,
+		},
+		{
+			_name:    "double star matches no dirs",
+			path:     "pkg/schema.sql",
+			patterns: []string{"pkg/**/*.sql"},
+			out:      true,
+		},
+		{
+			_name:    "double star other root",
+			path:     "cmd/schema.sql",
+			patterns: []string{"pkg/**/*.sql"},
+			out:      false,
+		},
+		{
+			_name:    "negation",
+			path:     "pkg/testdata/main.go",
+			patterns: []string{"*.go", "!**/testdata/**"},
+			out:      false,
+		},
+		{
+			_name:    "negation leaves others",
+			path:     "pkg/main.go",
+			patterns: []string{"*.go", "!**/testdata/**"},
+			out:      true,
+		},
+		{
+			_name:    "last match wins",
+			path:     "schema.sql",
+			patterns: []string{"*.sql", "!*.sql", "schema.sql"},
+			out:      true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -114,6 +152,42 @@ func TestScan(t *testing.T) {
 	}
 }
 
+func TestScanIgnoredDirs(t *testing.T) {
+	r := require.New(t)
+
+	dir := t.TempDir()
+	r.NoError(os.MkdirAll(filepath.Join(dir, "tmp", "cache"), 0o755))
+	r.NoError(os.WriteFile(filepath.Join(dir, "tmp", "keep.go"), []byte("package tmp"), 0o644))
+	r.NoError(os.WriteFile(filepath.Join(dir, "tmp", "cache", "build.go"), []byte("package cache"), 0o644))
+	r.NoError(os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0o644))
+
+	tests := []struct {
+		_name          string
+		ignorePatterns []string
+		out            []string
+	}{
+		{_name: "dir", ignorePatterns: []string{"tmp"}, out: []string{"main.go"}},
+		{_name: "dir contents", ignorePatterns: []string{"tmp/**"}, out: []string{"main.go"}},
+		{_name: "negated file", ignorePatterns: []string{"tmp/**", "!tmp/keep.go"}, out: []string{"main.go", "tmp/keep.go"}},
+		{_name: "negated name", ignorePatterns: []string{"tmp/**", "!build.go"}, out: []string{"main.go", "tmp/cache/build.go"}},
+		{_name: "negated elsewhere", ignorePatterns: []string{"tmp/**", "!src/keep.go"}, out: []string{"main.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+
+			w := watch.New(dir, nil, tt.ignorePatterns, func(string) {})
+			var out []string
+			for path := range w.Scan() {
+				rel, _ := filepath.Rel(dir, path)
+				out = append(out, filepath.ToSlash(rel))
+			}
+			a.ElementsMatch(tt.out, out)
+		})
+	}
+}
+
 func TestScanSkipsDotAndVendorDirs(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
@@ -132,50 +206,203 @@ func TestScanSkipsDotAndVendorDirs(t *testing.T) {
 	a.Equal(1, len(files))
 }
 
-func TestDetectsFileChange(t *testing.T) {
+func TestScanHonorsGitignore(t *testing.T) {
+	tests := []struct {
+		_name string
+		files map[string]string
+		out   []string
+	}{
+		{
+			_name: "root pattern at any depth",
+			files: map[string]string{
+				".gitignore":    "*.log\n",
+				"main.go":       "",
+				"app.log":       "",
+				"pkg/debug.log": "",
+			},
+			out: []string{"main.go"},
+		},
+		{
+			_name: "negation",
+			files: map[string]string{
+				".gitignore": "*.sql\n!schema.sql\n",
+				"dump.sql":   "",
+				"schema.sql": "",
+			},
+			out: []string{"schema.sql"},
+		},
+		{
+			_name: "directory only",
+			files: map[string]string{
+				".gitignore":   "tmp/\n",
+				"tmp/a.go":     "",
+				"pkg/tmp.go":   "",
+				"pkg/tmp/b.go": "",
+			},
+			out: []string{"pkg/tmp.go"},
+		},
+		{
+			_name: "anchored",
+			files: map[string]string{
+				".gitignore":      "/dist\n",
+				"dist/app.js":     "",
+				"web/dist/app.js": "",
+			},
+			out: []string{"web/dist/app.js"},
+		},
+		{
+			_name: "double star",
+			files: map[string]string{
+				".gitignore":             "assets/**/*.min.js\n",
+				"assets/app.js":          "",
+				"assets/app.min.js":      "",
+				"assets/vendor/x.min.js": "",
+			},
+			out: []string{"assets/app.js"},
+		},
+		{
+			_name: "nested gitignore",
+			files: map[string]string{
+				".gitignore":     "*.gen.go\n",
+				"api/.gitignore": "!*.gen.go\nout/\n",
+				"api/api.gen.go": "",
+				"api/out/x.go":   "",
+				"db/db.gen.go":   "",
+			},
+			out: []string{"api/api.gen.go"},
+		},
+		{
+			_name: "info exclude",
+			files: map[string]string{
+				".git/info/exclude": "artifacts\n",
+				"artifacts/bin.go":  "",
+				"main.go":           "",
+			},
+			out: []string{"main.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+			r := require.New(t)
+
+			dir := t.TempDir()
+			for name, body := range tt.files {
+				p := filepath.Join(dir, name)
+				r.NoError(os.MkdirAll(filepath.Dir(p), 0o755))
+				r.NoError(os.WriteFile(p, []byte(body), 0o644))
+			}
+
+			w := watch.New(dir, []string{"*.go", "*.js", "*.log", "*.sql"}, nil, func(string) {})
+			var out []string
+			for p := range w.Scan() {
+				rel, _ := filepath.Rel(dir, p)
+				out = append(out, filepath.ToSlash(rel))
+			}
+			a.ElementsMatch(tt.out, out)
+		})
+	}
+}
+
+func TestGitignoreInParentDir(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
 
-	dir := t.TempDir()
-	f := filepath.Join(dir, "main.go")
-	r.NoError(os.WriteFile(f, []byte("v1"), 0o644))
+	repo := t.TempDir()
+	dir := filepath.Join(repo, "apps", "web")
+	r.NoError(os.MkdirAll(filepath.Join(repo, ".git"), 0o755))
+	r.NoError(os.MkdirAll(filepath.Join(dir, "gen"), 0o755))
+	r.NoError(os.WriteFile(filepath.Join(repo, ".gitignore"), []byte("gen/\n"), 0o644))
+	r.NoError(os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0o644))
+	r.NoError(os.WriteFile(filepath.Join(dir, "gen", "gen.go"), []byte("package gen"), 0o644))
 
-	var changed int32
-	w := watch.New(dir, []string{"*.go"}, nil, func(path string) {
-		atomic.AddInt32(&changed, 1)
-	})
-	w.Start()
-	defer w.Stop()
+	w := watch.New(dir, []string{"*.go"}, nil, func(string) {})
+	files := w.Scan()
+	a.Len(files, 1)
+	a.Contains(files, filepath.Join(dir, "main.go"))
+}
 
-	time.Sleep(150 * time.Millisecond)
-	r.NoError(os.WriteFile(f, []byte("v2"), 0o644))
+func TestGitignoredChangeIgnored(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(string(backend), func(t *testing.T) {
+			r := require.New(t)
 
-	a.Eventually(func() bool {
-		return atomic.LoadInt32(&changed) > 0
-	}, 5*time.Second, 50*time.Millisecond)
-}
+			dir := t.TempDir()
+			r.NoError(os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("tmp/\n"), 0o644))
+			r.NoError(os.MkdirAll(filepath.Join(dir, "tmp"), 0o755))
 
-func TestDetectsFileDelete(t *testing.T) {
-	a := assert.New(t)
-	r := require.New(t)
+			var changed int32
+			w := watch.New(dir, []string{"*.go"}, nil, func(path string) {
+				atomic.AddInt32(&changed, 1)
+			})
+			w.Backend = backend
+			w.Start()
+			defer w.Stop()
 
-	dir := t.TempDir()
-	f := filepath.Join(dir, "main.go")
-	r.NoError(os.WriteFile(f, []byte("v1"), 0o644))
+			time.Sleep(150 * time.Millisecond)
+			r.NoError(os.WriteFile(filepath.Join(dir, "tmp", "main.go"), []byte("package main"), 0o644))
 
-	var changed int32
-	w := watch.New(dir, []string{"*.go"}, nil, func(path string) {
-		atomic.AddInt32(&changed, 1)
-	})
-	w.Start()
-	defer w.Stop()
+			time.Sleep(500 * time.Millisecond)
+			assert.New(t).Equal(int32(0), atomic.LoadInt32(&changed))
+		})
+	}
+}
 
-	time.Sleep(150 * time.Millisecond)
-	r.NoError(os.Remove(f))
+func TestDetectsFileChange(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(string(backend), func(t *testing.T) {
+			a := assert.New(t)
+			r := require.New(t)
+
+			dir := t.TempDir()
+			f := filepath.Join(dir, "main.go")
+			r.NoError(os.WriteFile(f, []byte("v1"), 0o644))
+
+			var changed int32
+			w := watch.New(dir, []string{"*.go"}, nil, func(path string) {
+				atomic.AddInt32(&changed, 1)
+			})
+			w.Backend = backend
+			w.Start()
+			defer w.Stop()
+
+			time.Sleep(150 * time.Millisecond)
+			r.NoError(os.WriteFile(f, []byte("v2"), 0o644))
+
+			a.Eventually(func() bool {
+				return atomic.LoadInt32(&changed) > 0
+			}, 5*time.Second, 50*time.Millisecond)
+		})
+	}
+}
 
-	a.Eventually(func() bool {
-		return atomic.LoadInt32(&changed) > 0
-	}, 5*time.Second, 50*time.Millisecond)
+func TestDetectsFileDelete(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(string(backend), func(t *testing.T) {
+			a := assert.New(t)
+			r := require.New(t)
+
+			dir := t.TempDir()
+			f := filepath.Join(dir, "main.go")
+			r.NoError(os.WriteFile(f, []byte("v1"), 0o644))
+
+			var changed int32
+			w := watch.New(dir, []string{"*.go"}, nil, func(path string) {
+				atomic.AddInt32(&changed, 1)
+			})
+			w.Backend = backend
+			w.Start()
+			defer w.Stop()
+
+			time.Sleep(150 * time.Millisecond)
+			r.NoError(os.Remove(f))
+
+			a.Eventually(func() bool {
+				return atomic.LoadInt32(&changed) > 0
+			}, 5*time.Second, 50*time.Millisecond)
+		})
+	}
 }
 
 func TestScanIgnoresDoNotEditFiles(t *testing.T) {
@@ -193,124 +420,282 @@ func TestScanIgnoresDoNotEditFiles(t *testing.T) {
 }
 
 func TestDoNotEditFileChangeIgnored(t *testing.T) {
-	r := require.New(t)
+	for _, backend := range backends {
+		t.Run(string(backend), func(t *testing.T) {
+			r := require.New(t)
+
+			dir := t.TempDir()
+			f := filepath.Join(dir, "generated.go")
+			r.NoError(os.WriteFile(f, []byte("// DO NOT EDIT\npackage gen\nv1"), 0o644))
+
+			var changed int32
+			w := watch.New(dir, []string{"*.go"}, nil, func(path string) {
+				atomic.AddInt32(&changed, 1)
+			})
+			w.Backend = backend
+			w.Start()
+			defer w.Stop()
+
+			time.Sleep(150 * time.Millisecond)
+			r.NoError(os.WriteFile(f, []byte("// DO NOT EDIT\npackage gen\nv2"), 0o644))
+
+			time.Sleep(500 * time.Millisecond)
+			assert.New(t).Equal(int32(0), atomic.LoadInt32(&changed))
+		})
+	}
+}
 
-	dir := t.TempDir()
-	f := filepath.Join(dir, "generated.go")
-	r.NoError(os.WriteFile(f, []byte("// DO NOT EDIT\npackage gen\nv1"), 0o644))
+func TestRefreshPicksUpNewFiles(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(string(backend), func(t *testing.T) {
+			a := assert.New(t)
+			r := require.New(t)
 
-	var changed int32
-	w := watch.New(dir, []string{"*.go"}, nil, func(path string) {
-		atomic.AddInt32(&changed, 1)
-	})
-	w.Start()
-	defer w.Stop()
+			dir := t.TempDir()
+			r.NoError(os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0o644))
+
+			var lastChanged atomic.Value
+			w := watch.New(dir, []string{"*.go"}, nil, func(path string) {
+				lastChanged.Store(path)
+			})
+			w.Backend = backend
+			w.Start()
+			defer w.Stop()
+
+			time.Sleep(150 * time.Millisecond)
 
-	time.Sleep(150 * time.Millisecond)
-	r.NoError(os.WriteFile(f, []byte("// DO NOT EDIT\npackage gen\nv2"), 0o644))
+			newFile := filepath.Join(dir, "new.go")
+			r.NoError(os.WriteFile(newFile, []byte("package main\nv1"), 0o644))
 
-	time.Sleep(500 * time.Millisecond)
-	assert.New(t).Equal(int32(0), atomic.LoadInt32(&changed))
+			w.RefreshFileList()
+
+			time.Sleep(150 * time.Millisecond)
+			r.NoError(os.WriteFile(newFile, []byte("package main\nv2"), 0o644))
+
+			a.Eventually(func() bool {
+				v := lastChanged.Load()
+				if v == nil {
+					return false
+				}
+				return v.(string) == newFile
+			}, 5*time.Second, 50*time.Millisecond)
+		})
+	}
 }
 
-func TestRefreshPicksUpNewFiles(t *testing.T) {
-	a := assert.New(t)
-	r := require.New(t)
+func TestRefreshForgetsRemovedFiles(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(string(backend), func(t *testing.T) {
+			a := assert.New(t)
+			r := require.New(t)
 
-	dir := t.TempDir()
-	r.NoError(os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0o644))
+			dir := t.TempDir()
+			f := filepath.Join(dir, "main.go")
+			r.NoError(os.WriteFile(f, []byte("package main"), 0o644))
 
-	var lastChanged atomic.Value
-	w := watch.New(dir, []string{"*.go"}, nil, func(path string) {
-		lastChanged.Store(path)
-	})
-	w.Start()
-	defer w.Stop()
+			w := watch.New(dir, []string{"*.go"}, nil, func(string) {})
+			w.Backend = backend
+			w.Start()
+			defer w.Stop()
 
-	time.Sleep(150 * time.Millisecond)
+			files := w.Scan()
+			a.Equal(1, len(files))
 
-	newFile := filepath.Join(dir, "new.go")
-	r.NoError(os.WriteFile(newFile, []byte("package main\nv1"), 0o644))
+			r.NoError(os.Remove(f))
+			w.RefreshFileList()
 
-	w.RefreshFileList()
+			files = w.Scan()
+			a.Equal(0, len(files))
+		})
+	}
+}
 
-	time.Sleep(150 * time.Millisecond)
-	r.NoError(os.WriteFile(newFile, []byte("package main\nv2"), 0o644))
+func TestRefreshForgetsRemovedDirs(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(string(backend), func(t *testing.T) {
+			a := assert.New(t)
+			r := require.New(t)
 
-	a.Eventually(func() bool {
-		v := lastChanged.Load()
-		if v == nil {
-			return false
-		}
-		return v.(string) == newFile
-	}, 5*time.Second, 50*time.Millisecond)
+			dir := t.TempDir()
+			sub := filepath.Join(dir, "pkg")
+			r.NoError(os.MkdirAll(sub, 0o755))
+			r.NoError(os.WriteFile(filepath.Join(sub, "a.go"), []byte("package pkg"), 0o644))
+			r.NoError(os.WriteFile(filepath.Join(sub, "b.go"), []byte("package pkg"), 0o644))
+			r.NoError(os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0o644))
+
+			w := watch.New(dir, []string{"*.go"}, nil, func(string) {})
+			w.Backend = backend
+			w.Start()
+			defer w.Stop()
+
+			files := w.Scan()
+			a.Equal(3, len(files))
+
+			r.NoError(os.RemoveAll(sub))
+			w.RefreshFileList()
+
+			files = w.Scan()
+			a.Equal(1, len(files))
+		})
+	}
 }
 
-func TestRefreshForgetsRemovedFiles(t *testing.T) {
+func TestStopIsGraceful(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(string(backend), func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := t.TempDir()
+			os.WriteFile(filepath.Join(dir, "f.txt"), []byte("hi"), 0o644)
+
+			w := watch.New(dir, nil, nil, func(string) {})
+			w.Backend = backend
+			w.Start()
+
+			done := make(chan struct{})
+			go func() {
+				w.Stop()
+				close(done)
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(2 * time.Second):
+				a.Fail("Stop did not return in time")
+			}
+		})
+	}
+}
+
+func TestNotifyWatchesNewDirs(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
 
 	dir := t.TempDir()
-	f := filepath.Join(dir, "main.go")
-	r.NoError(os.WriteFile(f, []byte("package main"), 0o644))
+	r.NoError(os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0o644))
 
-	w := watch.New(dir, []string{"*.go"}, nil, func(string) {})
+	var lastChanged atomic.Value
+	w := watch.New(dir, []string{"*.go"}, nil, func(path string) {
+		lastChanged.Store(path)
+	})
 	w.Start()
 	defer w.Stop()
+	a.Equal(watch.BackendNotify, w.Active())
 
-	files := w.Scan()
-	a.Equal(1, len(files))
+	sub := filepath.Join(dir, "pkg", "greet")
+	r.NoError(os.MkdirAll(sub, 0o755))
+	newFile := filepath.Join(sub, "greet.go")
+	r.NoError(os.WriteFile(newFile, []byte("package greet"), 0o644))
 
-	r.NoError(os.Remove(f))
-	w.RefreshFileList()
+	a.Eventually(func() bool {
+		v := lastChanged.Load()
+		return v != nil && v.(string) == newFile
+	}, 2*time.Second, 20*time.Millisecond)
+}
 
-	files = w.Scan()
-	a.Equal(0, len(files))
+func TestBatchesChanges(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(string(backend), func(t *testing.T) {
+			a := assert.New(t)
+			r := require.New(t)
+
+			dir := t.TempDir()
+			r.NoError(os.WriteFile(filepath.Join(dir, "a.go"), []byte("v1"), 0o644))
+			r.NoError(os.WriteFile(filepath.Join(dir, "b.go"), []byte("v1"), 0o644))
+
+			batches := make(chan []watch.Change, 10)
+			w := watch.NewBatch(dir, []string{"*.go"}, nil, func(changes []watch.Change) {
+				batches <- changes
+			})
+			w.Backend = backend
+			w.Quiet = 300 * time.Millisecond
+			w.Start()
+			defer w.Stop()
+
+			time.Sleep(150 * time.Millisecond)
+			r.NoError(os.WriteFile(filepath.Join(dir, "a.go"), []byte("v2"), 0o644))
+			r.NoError(os.Remove(filepath.Join(dir, "b.go")))
+			r.NoError(os.WriteFile(filepath.Join(dir, "c.go"), []byte("v1"), 0o644))
+			if backend == watch.BackendPoll {
+				w.RefreshFileList()
+			}
+
+			select {
+			case changes := <-batches:
+				a.Equal([]watch.Change{
+					{Op: watch.OpModified, Path: filepath.Join(dir, "a.go")},
+					{Op: watch.OpDeleted, Path: filepath.Join(dir, "b.go")},
+					{Op: watch.OpAdded, Path: filepath.Join(dir, "c.go")},
+				}, changes)
+			case <-time.After(5 * time.Second):
+				a.Fail("no batch")
+			}
+
+			select {
+			case changes := <-batches:
+				a.Fail("unexpected second batch", "%v", changes)
+			case <-time.After(500 * time.Millisecond):
+			}
+		})
+	}
 }
 
-func TestRefreshForgetsRemovedDirs(t *testing.T) {
+func TestBatchDropsAddedThenDeleted(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
 
 	dir := t.TempDir()
-	sub := filepath.Join(dir, "pkg")
-	r.NoError(os.MkdirAll(sub, 0o755))
-	r.NoError(os.WriteFile(filepath.Join(sub, "a.go"), []byte("package pkg"), 0o644))
-	r.NoError(os.WriteFile(filepath.Join(sub, "b.go"), []byte("package pkg"), 0o644))
-	r.NoError(os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0o644))
+	r.NoError(os.WriteFile(filepath.Join(dir, "main.go"), []byte("v1"), 0o644))
 
-	w := watch.New(dir, []string{"*.go"}, nil, func(string) {})
+	batches := make(chan []watch.Change, 10)
+	w := watch.NewBatch(dir, []string{"*.go"}, nil, func(changes []watch.Change) {
+		batches <- changes
+	})
+	w.Quiet = 300 * time.Millisecond
 	w.Start()
 	defer w.Stop()
 
-	files := w.Scan()
-	a.Equal(3, len(files))
-
-	r.NoError(os.RemoveAll(sub))
-	w.RefreshFileList()
+	tmp := filepath.Join(dir, "tmp.go")
+	r.NoError(os.WriteFile(tmp, []byte("v1"), 0o644))
+	time.Sleep(50 * time.Millisecond)
+	r.NoError(os.Remove(tmp))
+	r.NoError(os.WriteFile(filepath.Join(dir, "main.go"), []byte("v2"), 0o644))
 
-	files = w.Scan()
-	a.Equal(1, len(files))
+	select {
+	case changes := <-batches:
+		a.Equal([]watch.Change{{Op: watch.OpModified, Path: filepath.Join(dir, "main.go")}}, changes)
+	case <-time.After(5 * time.Second):
+		a.Fail("no batch")
+	}
 }
 
-func TestStopIsGraceful(t *testing.T) {
+func TestNotifyTracksEmptyFiles(t *testing.T) {
 	a := assert.New(t)
+	r := require.New(t)
 
 	dir := t.TempDir()
-	os.WriteFile(filepath.Join(dir, "f.txt"), []byte("hi"), 0o644)
+	r.NoError(os.WriteFile(filepath.Join(dir, "main.go"), []byte("v1"), 0o644))
 
-	w := watch.New(dir, nil, nil, func(string) {})
+	batches := make(chan []watch.Change, 10)
+	w := watch.NewBatch(dir, []string{"*.go"}, nil, func(changes []watch.Change) {
+		batches <- changes
+	})
+	w.Quiet = 300 * time.Millisecond
 	w.Start()
+	defer w.Stop()
+	a.Equal(watch.BackendNotify, w.Active())
 
-	done := make(chan struct{})
-	go func() {
-		w.Stop()
-		close(done)
-	}()
+	empty := filepath.Join(dir, "empty.go")
+	r.NoError(os.WriteFile(empty, nil, 0o644))
+	gen := filepath.Join(dir, "gen.go")
+	r.NoError(os.WriteFile(gen, nil, 0o644))
+	time.Sleep(20 * time.Millisecond)
+	r.NoError(os.WriteFile(gen, []byte("// DO NOT EDIT\npackage gen"), 0o644))
 
 	select {
-	case <-done:
-	case <-time.After(2 * time.Second):
-		a.Fail("Stop did not return in time")
+	case changes := <-batches:
+		a.Equal([]watch.Change{{Op: watch.OpAdded, Path: empty}}, changes)
+	case <-time.After(5 * time.Second):
+		a.Fail("no batch")
 	}
 }
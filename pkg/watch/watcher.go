@@ -1,36 +1,68 @@
 package watch
 
-// Standalone file watcher inspired by esbuild's polling watcher.
-// Uses randomized scan order with a recent-items fast path to balance
-// CPU usage against change detection latency.
-
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"log/slog"
-	"math/rand"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
+// Backend selects how a Watcher notices changes.
+type Backend string
+
 const (
-	watchIntervalSleep       = 100 * time.Millisecond
-	maxRecentItemCount       = 16
-	minItemCountPerIter      = 64
-	maxIntervalsBeforeUpdate = 20
-	rescanInterval           = 50 // ~5 seconds between full rescans
+	// BackendNotify uses inotify and falls back to polling when watch limits
+	// are hit. It is the default.
+	BackendNotify Backend = "notify"
+	BackendPoll   Backend = "poll"
 )
 
+// DefaultQuiet is how long a batch watcher waits for changes to settle.
+const DefaultQuiet = 200 * time.Millisecond
+
+// Op classifies a change to a watched file.
+type Op string
+
+const (
+	OpAdded    Op = "added"
+	OpDeleted  Op = "deleted"
+	OpModified Op = "modified"
+)
+
+type Change struct {
+	Op   Op
+	Path string
+}
+
 type Watcher struct {
+	// Backend is read by Start. Empty means BackendNotify.
+	Backend Backend
+	// Quiet is how long NewBatch watchers wait after the last change before
+	// reporting a batch. Zero means DefaultQuiet.
+	Quiet time.Duration
+
 	dir            string
 	patterns       []string
 	ignorePatterns []string
+	onBatch        func([]Change)
 	onChange       func(path string)
 
+	active            Backend
+	batchMu           sync.Mutex
+	batchTimer        *time.Timer
+	flushMu           sync.Mutex
+	pending           map[string]Op
+	excludeFile       string
+	ignoreMu          sync.Mutex
+	ignoreRules       map[string][]ignoreRule
 	modTimes          map[string]time.Time
 	recentItems       []string
 	itemsToScan       []string
@@ -39,6 +71,8 @@ type Watcher struct {
 	mutex             sync.Mutex
 	stopWaitGroup     sync.WaitGroup
 	logger            *slog.Logger
+	notify            *notifier
+	repoRoot          string
 }
 
 func New(dir string, patterns, ignorePatterns []string, onChange func(path string)) *Watcher {
@@ -51,59 +85,79 @@ func New(dir string, patterns, ignorePatterns []string, onChange func(path strin
 	}
 }
 
+// NewBatch returns a watcher that reports changes in batches once Quiet
+// has passed without another change, so a branch switch touching many
+// files is reported once. Calls to onBatch never overlap.
+func NewBatch(dir string, patterns, ignorePatterns []string, onBatch func([]Change)) *Watcher {
+	w := New(dir, patterns, ignorePatterns, nil)
+	w.onBatch = onBatch
+	return w
+}
+
 func (w *Watcher) Start() {
 	w.modTimes = w.Scan()
-	w.logger.Info("watch", "dir", w.dir, "files", len(w.modTimes))
-
-	w.stopWaitGroup.Add(1)
-	go func() {
-		defer w.stopWaitGroup.Done()
-		rescanCounter := 0
-		for atomic.LoadInt32(&w.shouldStop) == 0 {
-			time.Sleep(watchIntervalSleep)
-
-			rescanCounter++
-			if rescanCounter >= rescanInterval {
-				w.RefreshFileList()
-				rescanCounter = 0
-			}
+	files := len(w.modTimes)
 
-			if dirtyPath := w.tryToFindDirtyPath(); dirtyPath != "" {
-				w.onChange(dirtyPath)
-			}
+	active := BackendPoll
+	if w.Backend != BackendPoll {
+		if err := w.startNotify(); err != nil {
+			w.logger.Warn("watch falling back to polling", "error", err)
+		} else {
+			active = BackendNotify
 		}
-	}()
+	}
+	w.mutex.Lock()
+	w.active = active
+	w.mutex.Unlock()
+	if active == BackendPoll {
+		w.startPoll()
+	}
+
+	w.logger.Info("watch", "dir", w.dir, "files", files, "backend", active)
 }
 
 func (w *Watcher) Stop() {
 	atomic.StoreInt32(&w.shouldStop, 1)
+	w.mutex.Lock()
+	n := w.notify
+	w.mutex.Unlock()
+	if n != nil {
+		n.close()
+	}
 	w.stopWaitGroup.Wait()
+
+	w.batchMu.Lock()
+	if w.batchTimer != nil {
+		w.batchTimer.Stop()
+	}
+	w.pending = nil
+	w.batchMu.Unlock()
+}
+
+// Active reports the backend in use after Start.
+func (w *Watcher) Active() Backend {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.active
 }
 
 func (w *Watcher) Scan() map[string]time.Time {
+	return w.scan(w.dir)
+}
+
+func (w *Watcher) scan(root string) map[string]time.Time {
 	result := make(map[string]time.Time)
-	filepath.WalkDir(w.dir, func(path string, d os.DirEntry, err error) error {
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return nil
 		}
 		if d.IsDir() {
-			name := d.Name()
-			if strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor" {
-				return filepath.SkipDir
-			}
-			if MatchesAny(name, w.ignorePatterns) {
+			if w.skipDir(path) {
 				return filepath.SkipDir
 			}
 			return nil
 		}
-		relPath, _ := filepath.Rel(w.dir, path)
-		if len(w.patterns) > 0 && !MatchesAny(relPath, w.patterns) {
-			return nil
-		}
-		if MatchesAny(relPath, w.ignorePatterns) {
-			return nil
-		}
-		if hasDoNotEdit(path) {
+		if !w.wants(path) {
 			return nil
 		}
 		info, err := d.Info()
@@ -116,94 +170,131 @@ func (w *Watcher) Scan() map[string]time.Time {
 	return result
 }
 
+// RefreshFileList rescans the tree and reports files that appeared or
+// disappeared since the last scan.
 func (w *Watcher) RefreshFileList() {
 	w.mutex.Lock()
-	defer w.mutex.Unlock()
 
+	w.resetIgnores()
 	fresh := w.Scan()
 
+	var changes []Change
 	// Remove deleted files
 	for path := range w.modTimes {
 		if _, ok := fresh[path]; !ok {
 			delete(w.modTimes, path)
+			changes = append(changes, Change{Op: OpDeleted, Path: path})
 		}
 	}
 	// Add new files
 	for path, modTime := range fresh {
 		if _, ok := w.modTimes[path]; !ok {
 			w.modTimes[path] = modTime
+			changes = append(changes, Change{Op: OpAdded, Path: path})
 		}
 	}
 
-	// Reset scan list so it gets rebuilt on next tryToFindDirtyPath
+	// Reset scan list so it gets rebuilt on next tryToFindDirtyPaths
 	w.itemsToScan = w.itemsToScan[:0]
-}
+	w.mutex.Unlock()
 
-func (w *Watcher) tryToFindDirtyPath() string {
-	w.mutex.Lock()
-	defer w.mutex.Unlock()
+	w.emit(changes...)
+}
 
-	// Rebuild scan list if empty
-	if len(w.itemsToScan) == 0 {
-		items := w.itemsToScan[:0]
-		for path := range w.modTimes {
-			items = append(items, path)
-		}
-		rand.Shuffle(len(items), func(i, j int) {
-			items[i], items[j] = items[j], items[i]
-		})
-		w.itemsToScan = items
-
-		perIter := (len(items) + maxIntervalsBeforeUpdate - 1) / maxIntervalsBeforeUpdate
-		if perIter < minItemCountPerIter {
-			perIter = minItemCountPerIter
+// emit reports changes, either one path at a time to onChange or merged
+// into the pending batch.
+func (w *Watcher) emit(changes ...Change) {
+	if len(changes) == 0 || w.stopping() {
+		return
+	}
+	if w.onBatch == nil {
+		for _, c := range changes {
+			w.onChange(c.Path)
 		}
-		w.itemsPerIteration = perIter
+		return
+	}
+
+	quiet := w.Quiet
+	if quiet == 0 {
+		quiet = DefaultQuiet
 	}
 
-	// Always check recent items first
-	for i, path := range w.recentItems {
-		if w.isDirty(path) {
-			copy(w.recentItems[i:], w.recentItems[i+1:])
-			w.recentItems[len(w.recentItems)-1] = path
-			return path
+	w.batchMu.Lock()
+	defer w.batchMu.Unlock()
+	if w.pending == nil {
+		w.pending = map[string]Op{}
+	}
+	for _, c := range changes {
+		op, keep := mergeOp(w.pending[c.Path], c.Op)
+		if keep {
+			w.pending[c.Path] = op
+		} else {
+			delete(w.pending, c.Path)
 		}
 	}
+	if w.batchTimer == nil {
+		w.batchTimer = time.AfterFunc(quiet, w.flush)
+	} else {
+		w.batchTimer.Reset(quiet)
+	}
+}
 
-	// Check a batch from the scan list
-	remainingCount := len(w.itemsToScan) - w.itemsPerIteration
-	if remainingCount < 0 {
-		remainingCount = 0
+// mergeOp folds next into an earlier pending op for the same path. A file
+// added and deleted within one batch drops out of it.
+func mergeOp(prev, next Op) (Op, bool) {
+	switch {
+	case prev == OpAdded && next == OpDeleted:
+		return "", false
+	case prev == OpAdded:
+		return OpAdded, true
+	case prev == OpDeleted && next == OpAdded:
+		return OpModified, true
 	}
-	toCheck := w.itemsToScan[remainingCount:]
-	w.itemsToScan = w.itemsToScan[:remainingCount]
+	return next, true
+}
 
-	for _, path := range toCheck {
-		if w.isDirty(path) {
-			w.recentItems = append(w.recentItems, path)
-			if len(w.recentItems) > maxRecentItemCount {
-				copy(w.recentItems, w.recentItems[1:])
-				w.recentItems = w.recentItems[:maxRecentItemCount]
-			}
-			return path
-		}
+func (w *Watcher) flush() {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.batchMu.Lock()
+	batch := make([]Change, 0, len(w.pending))
+	for path, op := range w.pending {
+		batch = append(batch, Change{Op: op, Path: path})
 	}
+	w.pending = nil
+	w.batchTimer = nil
+	w.batchMu.Unlock()
 
-	return ""
+	if len(batch) == 0 || w.stopping() {
+		return
+	}
+	sort.Slice(batch, func(i, j int) bool { return batch[i].Path < batch[j].Path })
+	w.onBatch(batch)
 }
 
-func (w *Watcher) isDirty(path string) bool {
-	info, err := os.Stat(path)
-	if err != nil {
-		// File was deleted
-		delete(w.modTimes, path)
-		return true
+func (w *Watcher) skipDir(path string) bool {
+	if path == w.dir {
+		return false
 	}
-	if info.ModTime().After(w.modTimes[path]) {
-		w.modTimes[path] = info.ModTime()
+	name := filepath.Base(path)
+	if strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor" {
 		return true
 	}
-	return false
+	relPath, _ := filepath.Rel(w.dir, path)
+	return matchesDir(relPath, w.ignorePatterns) || w.gitIgnored(path, true)
+}
+
+// wants reports whether the file at path is one the watcher tracks.
+func (w *Watcher) wants(path string) bool {
+	relPath, _ := filepath.Rel(w.dir, path)
+	if len(w.patterns) > 0 && !MatchesAny(relPath, w.patterns) {
+		return false
+	}
+	if MatchesAny(relPath, w.ignorePatterns) || w.gitIgnored(path, false) {
+		return false
+	}
+	return !hasDoNotEdit(path)
 }
 
 var doNotEditMarker = []byte("DO NOT EDIT")
@@ -223,15 +314,41 @@ func hasDoNotEdit(path string) bool {
 	return bytes.Contains(buf[:n], doNotEditMarker)
 }
 
+// Hash returns the hex SHA-256 of the file at path, or "" if it can't be
+// read.
+func Hash(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// MatchesAny reports whether path, relative to the watched dir, matches
+// patterns. Patterns without a slash match the base name; others match the
+// whole path, with "**" matching any number of directories. A pattern
+// starting with "!" excludes what earlier patterns matched, and the last
+// matching pattern wins.
 func MatchesAny(path string, patterns []string) bool {
+	path = filepath.ToSlash(path)
 	name := filepath.Base(path)
+	matched := false
 	for _, pattern := range patterns {
-		if matched, _ := filepath.Match(pattern, name); matched {
-			return true
+		negate := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+		target := path
+		if !strings.Contains(pattern, "/") {
+			target = name
 		}
-		if matched, _ := filepath.Match(pattern, path); matched {
-			return true
+		if matchGlob(strings.TrimPrefix(pattern, "/"), target) {
+			matched = !negate
 		}
 	}
-	return false
+	return matched
 }
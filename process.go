@@ -0,0 +1,347 @@
+package cheetah
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/housecat-inc/cheetah/pkg/api"
+	"github.com/housecat-inc/cheetah/pkg/build"
+	"github.com/housecat-inc/cheetah/pkg/logs"
+)
+
+const (
+	crashLoopCrashes = 3
+	crashTailLines   = 50
+	maxBackoff       = 30 * time.Second
+	stableAfter      = time.Minute
+)
+
+// process is a running app binary: the web process on a port, or a worker
+// with port 0. A supervisor goroutine owns cmd.Wait and closes done when the
+// process exits.
+type process struct {
+	build    string
+	cmd      *exec.Cmd
+	command  string
+	crashes  int
+	done     chan struct{}
+	env      []string
+	name     string
+	port     int
+	started  time.Time
+	stopping atomic.Bool
+	tail     *logs.Tail
+}
+
+func (r *appRunner) supervise(cmd *exec.Cmd, name string, port int, tail *logs.Tail) *process {
+	p := &process{
+		cmd:     cmd,
+		done:    make(chan struct{}),
+		name:    name,
+		port:    port,
+		started: time.Now(),
+		tail:    tail,
+	}
+	go func() {
+		cmd.Wait()
+		close(p.done)
+		if p.stopping.Load() {
+			return
+		}
+		if port == 0 {
+			r.workerCrashed(p)
+		} else {
+			r.crashed(p)
+		}
+	}()
+	return p
+}
+
+// startWorkers moves every worker onto a's binaries and stops workers that
+// are gone. Workers already running a with the same command and env keep
+// running. Old workers are stopped outside mu, since that can take seconds.
+func (r *appRunner) startWorkers(a keptBuild) error {
+	r.mu.Lock()
+	names := map[string]bool{}
+	for name := range r.workers {
+		names[name] = true
+	}
+	for name := range a.workers {
+		names[name] = true
+	}
+	for name := range r.commands {
+		names[name] = true
+	}
+	var stale []string
+	var old []*process
+	for name := range names {
+		if !r.workerStaleLocked(name, a) {
+			continue
+		}
+		stale = append(stale, name)
+		if p := r.workers[name]; p != nil {
+			old = append(old, p)
+			delete(r.workers, name)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, p := range old {
+		p.stop()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var errs []error
+	for _, name := range stale {
+		if _, err := r.startWorkerLocked(name, a); err != nil {
+			errs = append(errs, errors.Wrap(err, name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// workerStaleLocked reports whether the worker called name has to restart
+// to run a: it isn't running, or its build, command or env changed.
+func (r *appRunner) workerStaleLocked(name string, a keptBuild) bool {
+	p := r.workers[name]
+	if p == nil {
+		return true
+	}
+	select {
+	case <-p.done:
+		return true
+	default:
+	}
+	_, isTarget := a.workers[name]
+	command, isCommand := r.commands[name]
+	if !isTarget && !isCommand {
+		return true
+	}
+	return p.build != a.ID || p.command != command || !slices.Equal(p.env, build.Env(r.buildIn(0)))
+}
+
+// startWorkerLocked starts the worker called name with a: as a Procfile
+// command, or as a's target binary if it has one. The worker it replaces
+// has already exited or been stopped.
+func (r *appRunner) startWorkerLocked(name string, a keptBuild) (*process, error) {
+	delete(r.workers, name)
+
+	tail := logs.NewTail(crashTailLines)
+	in := r.buildIn(0)
+	in.Output = io.MultiWriter(r.output.Process(name), tail)
+
+	command, isCommand := r.commands[name]
+	binary, isTarget := a.workers[name]
+	var cmd *exec.Cmd
+	var err error
+	switch {
+	case isCommand:
+		cmd, err = build.StartCommand(in, command, filepath.Dir(a.binary))
+	case isTarget:
+		cmd, err = build.Start(in, binary)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p := r.supervise(cmd, name, 0, tail)
+	p.build = a.ID
+	p.command = command
+	p.env = build.Env(in)
+	r.workers[name] = p
+	return p, nil
+}
+
+// reportProcesses sends the web and worker processes to the dashboard.
+func (r *appRunner) reportProcesses() {
+	r.mu.Lock()
+	out := make([]api.Process, 0, len(r.procs)+len(r.workers))
+	for _, p := range r.procs {
+		out = append(out, p.info())
+	}
+	for _, p := range r.workers {
+		out = append(out, p.info())
+	}
+	r.mu.Unlock()
+
+	slices.SortFunc(out, func(a, b api.Process) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.Port, b.Port))
+	})
+	r.client.ProcessesPut(r.space, out)
+}
+
+// info is p's state for the dashboard.
+func (p *process) info() api.Process {
+	out := api.Process{
+		Build:     p.build,
+		Command:   p.command,
+		Name:      p.name,
+		Pid:       p.cmd.Process.Pid,
+		Port:      p.port,
+		StartedAt: p.started,
+		Status:    "running",
+	}
+	select {
+	case <-p.done:
+		out.ExitCode = p.cmd.ProcessState.ExitCode()
+		out.Status = "crashed"
+	default:
+	}
+	return out
+}
+
+func (p *process) stop() {
+	if p == nil || p.cmd.Process == nil {
+		return
+	}
+	p.stopping.Store(true)
+
+	p.signal(syscall.SIGTERM)
+
+	select {
+	case <-p.done:
+	case <-time.After(5 * time.Second):
+		p.signal(syscall.SIGKILL)
+		<-p.done
+	}
+}
+
+// signal sends sig to p, or to its whole group when it has its own.
+func (p *process) signal(sig syscall.Signal) {
+	if a := p.cmd.SysProcAttr; a != nil && a.Setpgid {
+		syscall.Kill(-p.cmd.Process.Pid, sig)
+		return
+	}
+	p.cmd.Process.Signal(sig)
+}
+
+// crashed handles a process exiting on its own. Only the active process
+// counts; one that dies while a swap waits on it fails the swap instead.
+func (r *appRunner) crashed(p *process) {
+	r.mu.Lock()
+	if r.procs[p.port] != p || r.ports.Active() != p.port {
+		r.mu.Unlock()
+		return
+	}
+	if time.Since(p.started) >= stableAfter {
+		r.crashes = 0
+	}
+	r.crashes++
+	crashes := r.crashes
+	r.mu.Unlock()
+
+	crash := api.Crash{
+		At:       time.Now(),
+		Crashes:  crashes,
+		ExitCode: p.cmd.ProcessState.ExitCode(),
+		Output:   p.tail.Lines(),
+	}
+	if ws, ok := p.cmd.ProcessState.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		crash.Signal = ws.Signal().String()
+	}
+
+	status := "crashed"
+	if crashes >= crashLoopCrashes {
+		status = "crashloop"
+	}
+	r.logger.Error("app "+status, "port", p.port, "exit", crash.ExitCode, "signal", crash.Signal, "crashes", crashes)
+	r.client.CrashUpdate(r.space, status, crash)
+	r.reportProcesses()
+
+	if r.restart {
+		r.restartAfter(p, crashes)
+	}
+}
+
+// restartAfter queues a relaunch of the last build once the backoff for
+// crashes has passed. A failed relaunch counts as another crash.
+func (r *appRunner) restartAfter(p *process, crashes int) {
+	delay := backoff(crashes)
+	r.logger.Info("restarting", "in", delay)
+	time.AfterFunc(delay, func() {
+		r.queue(func() {
+			r.mu.Lock()
+			current := r.procs[p.port] == p
+			r.mu.Unlock()
+			if !current {
+				return
+			}
+			if r.swap(r.launch) {
+				return
+			}
+
+			r.mu.Lock()
+			r.crashes++
+			crashes := r.crashes
+			r.mu.Unlock()
+			r.sendLog("error", fmt.Sprintf("restart after crash %d failed", crashes-1), "event", api.EventSwap)
+			r.restartAfter(p, crashes)
+		})
+	})
+}
+
+// workerCrashed handles a worker exiting on its own. With WithRestart it is
+// started again from the current build after a backoff.
+func (r *appRunner) workerCrashed(p *process) {
+	r.mu.Lock()
+	if r.workers[p.name] != p {
+		r.mu.Unlock()
+		return
+	}
+	r.mu.Unlock()
+
+	exit := p.cmd.ProcessState.ExitCode()
+	r.logger.Error("worker crashed", "name", p.name, "exit", exit)
+	r.sendLog("error", fmt.Sprintf("worker %s crashed with exit code %d", p.name, exit), "process", p.name)
+	r.reportProcesses()
+	if !r.restart {
+		return
+	}
+
+	crashes := p.crashes + 1
+	if time.Since(p.started) >= stableAfter {
+		crashes = 1
+	}
+	delay := backoff(crashes)
+	r.logger.Info("restarting", "name", p.name, "in", delay)
+	time.AfterFunc(delay, func() {
+		r.mu.Lock()
+		if r.workers[p.name] != p {
+			r.mu.Unlock()
+			return
+		}
+		np, err := r.startWorkerLocked(p.name, r.current)
+		if np != nil {
+			np.crashes = crashes
+		}
+		r.mu.Unlock()
+
+		if err != nil {
+			r.logger.Error("worker restart failed", "name", p.name, "error", err)
+			r.sendLog("error", fmt.Sprintf("worker %s restart failed: %v", p.name, err), "process", p.name)
+		}
+		r.reportProcesses()
+	})
+}
+
+func backoff(crashes int) time.Duration {
+	d := time.Second
+	for i := 1; i < crashes && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
@@ -0,0 +1,69 @@
+package cheetah
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/housecat-inc/cheetah/pkg/api"
+	"github.com/housecat-inc/cheetah/pkg/logs"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		_name   string
+		crashes int
+		out     time.Duration
+	}{
+		{_name: "first crash", crashes: 1, out: time.Second},
+		{_name: "doubles", crashes: 3, out: 4 * time.Second},
+		{_name: "caps", crashes: 10, out: maxBackoff},
+	}
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+			a.Equal(tt.out, backoff(tt.crashes))
+		})
+	}
+}
+
+func TestStartWorkers(t *testing.T) {
+	tests := []struct {
+		_name   string
+		build   string
+		command string
+		env     map[string]string
+		out     bool
+	}{
+		{_name: "same build", build: "a", command: "sleep 30"},
+		{_name: "new build", build: "b", command: "sleep 30", out: true},
+		{_name: "new command", build: "a", command: "sleep 31", out: true},
+		{_name: "new env", build: "a", command: "sleep 30", env: map[string]string{"QUEUE": "low"}, out: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+
+			output := logs.NewCollector(io.Discard, nil)
+			defer output.Close()
+			r := &appRunner{
+				commands: map[string]string{"jobs": "sleep 30"},
+				output:   output,
+				resp:     &api.AppOut{},
+				workers:  map[string]*process{},
+			}
+			defer r.stopAll()
+
+			a.NoError(r.startWorkers(keptBuild{Artifact: api.Artifact{ID: "a"}}))
+			first := r.workers["jobs"]
+
+			r.commands["jobs"] = tt.command
+			r.appEnv = tt.env
+			a.NoError(r.startWorkers(keptBuild{Artifact: api.Artifact{ID: tt.build}}))
+			a.Equal(tt.out, r.workers["jobs"] != first)
+			a.Equal(tt.build, r.workers["jobs"].build)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package cheetah
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+var processNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// loadProcesses reads worker commands by name from the Procfile in dir and
+// then the processes section of cheetah.yaml, which wins for a name in
+// both. The Procfile's web entry is skipped since cheetah builds and swaps
+// the web process itself.
+func loadProcesses(dir string) (map[string]string, error) {
+	procs := map[string]string{}
+
+	data, err := os.ReadFile(filepath.Join(dir, "Procfile"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "read Procfile")
+	}
+	if err == nil {
+		if procs, err = parseProcfile(data); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err = os.ReadFile(filepath.Join(dir, "cheetah.yaml"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "read cheetah.yaml")
+	}
+	if err == nil {
+		var cfg struct {
+			Processes map[string]string `yaml:"processes"`
+		}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, errors.Wrap(err, "parse cheetah.yaml")
+		}
+		for name, command := range cfg.Processes {
+			if !processNameRe.MatchString(name) || strings.TrimSpace(command) == "" {
+				return nil, errors.Newf("cheetah.yaml process %q needs a name of letters, digits, - or _ and a command", name)
+			}
+			procs[name] = command
+		}
+	}
+	return procs, nil
+}
+
+func parseProcfile(data []byte) (map[string]string, error) {
+	procs := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, command, ok := strings.Cut(line, ":")
+		name, command = strings.TrimSpace(name), strings.TrimSpace(command)
+		if !ok || !processNameRe.MatchString(name) || command == "" {
+			return nil, errors.Newf("Procfile line %d: want name: command", n)
+		}
+		if name == "web" {
+			continue
+		}
+		procs[name] = command
+	}
+	return procs, nil
+}
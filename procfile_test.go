@@ -0,0 +1,52 @@
+package cheetah
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadProcesses(t *testing.T) {
+	tests := []struct {
+		_name    string
+		procfile string
+		yaml     string
+		err      bool
+		out      map[string]string
+	}{
+		{_name: "none", out: map[string]string{}},
+		{
+			_name:    "procfile",
+			procfile: "# workers\nweb: app\nworker: worker --queue=default\n\nclock:  go run ./cmd/clock\n",
+			out:      map[string]string{"clock": "go run ./cmd/clock", "worker": "worker --queue=default"},
+		},
+		{
+			_name:    "yaml wins",
+			procfile: "worker: worker\n",
+			yaml:     "processes:\n  worker: worker --queue=low\n  mailer: go run ./cmd/mailer\n",
+			out:      map[string]string{"mailer": "go run ./cmd/mailer", "worker": "worker --queue=low"},
+		},
+		{_name: "bad procfile line", procfile: "worker\n", err: true},
+		{_name: "bad name", procfile: "my worker: run\n", err: true},
+		{_name: "empty yaml command", yaml: "processes:\n  worker: \"\"\n", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := t.TempDir()
+			if tt.procfile != "" {
+				os.WriteFile(filepath.Join(dir, "Procfile"), []byte(tt.procfile), 0o644)
+			}
+			if tt.yaml != "" {
+				os.WriteFile(filepath.Join(dir, "cheetah.yaml"), []byte(tt.yaml), 0o644)
+			}
+			out, err := loadProcesses(dir)
+			a.Equal(tt.err, err != nil)
+			a.Equal(tt.out, out)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package cheetah
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/housecat-inc/cheetah/pkg/api"
+)
+
+const maxArtifacts = 5
+
+// keptBuild is a successful build kept for rollback: the web binary and the
+// worker binaries by name.
+type keptBuild struct {
+	api.Artifact
+	binary  string
+	workers map[string]string
+}
+
+// keepLocked records a and drops the oldest binaries beyond maxArtifacts,
+// skipping any that a process is still running.
+func (r *appRunner) keepLocked(a keptBuild) {
+	r.artifacts = append(r.artifacts, a)
+
+	running := map[string]bool{r.current.ID: true}
+	for _, p := range r.procs {
+		running[p.build] = true
+	}
+	for _, p := range r.workers {
+		running[p.build] = true
+	}
+	for i := 0; len(r.artifacts) > maxArtifacts && i < len(r.artifacts); {
+		old := r.artifacts[i]
+		if running[old.ID] {
+			i++
+			continue
+		}
+		r.store.Delete(r.space, old.ID)
+		r.artifacts = slices.Delete(r.artifacts, i, i+1)
+	}
+}
+
+// activeBuildLocked is the ID of the build serving the active port.
+func (r *appRunner) activeBuildLocked() string {
+	if p, ok := r.procs[r.ports.Active()]; ok {
+		return p.build
+	}
+	return ""
+}
+
+// reportArtifacts sends the kept builds, newest first, to the dashboard.
+func (r *appRunner) reportArtifacts() {
+	r.mu.Lock()
+	active := r.activeBuildLocked()
+	out := make([]api.Artifact, 0, len(r.artifacts))
+	for i := len(r.artifacts) - 1; i >= 0; i-- {
+		a := r.artifacts[i].Artifact
+		a.Active = a.ID == active
+		out = append(out, a)
+	}
+	r.mu.Unlock()
+
+	r.client.ArtifactsPut(r.space, out)
+}
+
+// findArtifactLocked returns the kept build with id, or with an empty id the
+// one built before the active build.
+func (r *appRunner) findArtifactLocked(id string) (keptBuild, bool) {
+	if id == "" {
+		active := r.activeBuildLocked()
+		i := slices.IndexFunc(r.artifacts, func(a keptBuild) bool { return a.ID == active })
+		if i < 0 {
+			i = len(r.artifacts) - 1
+		}
+		if i < 1 {
+			return keptBuild{}, false
+		}
+		return r.artifacts[i-1], true
+	}
+	i := slices.IndexFunc(r.artifacts, func(a keptBuild) bool { return a.ID == id })
+	if i < 0 {
+		return keptBuild{}, false
+	}
+	return r.artifacts[i], true
+}
+
+// rollback swaps to a kept build without rebuilding. Later restarts keep
+// using it until the next successful build.
+func (r *appRunner) rollback(id string) {
+	r.mu.Lock()
+	a, ok := r.findArtifactLocked(id)
+	r.mu.Unlock()
+	if !ok {
+		msg := "rollback failed: no previous build"
+		if id != "" {
+			msg = fmt.Sprintf("rollback failed: build %s not found", id)
+		}
+		r.logger.Error(msg)
+		r.sendLog("error", msg)
+		return
+	}
+
+	launch := func(port int) (keptBuild, error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return a, r.launchLocked(port, a)
+	}
+	if !r.swap(launch) {
+		r.logger.Error("swap failed")
+		r.sendLog("error", fmt.Sprintf("swap failed during rollback to %s", a.ID), "event", api.EventSwap)
+		return
+	}
+
+	// The inputs on record are the newer build's, so the next change to any
+	// file rebuilds.
+	r.buildMu.Lock()
+	r.inputs = nil
+	r.buildMu.Unlock()
+
+	r.logger.Info("rollback", "build", a.ID, "commit", a.Commit)
+	r.sendLog("info", fmt.Sprintf("rolled back to build %s", a.ID), "build", a.ID, "commit", a.Commit)
+}
@@ -0,0 +1,156 @@
+package cheetah
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/housecat-inc/cheetah/pkg/api"
+	"github.com/housecat-inc/cheetah/pkg/artifact"
+	"github.com/housecat-inc/cheetah/pkg/logs"
+	"github.com/housecat-inc/cheetah/pkg/port"
+)
+
+func testArtifact(store artifact.Store, id string) keptBuild {
+	dir, _ := store.Create("buffalo", id)
+	bin := filepath.Join(dir, "app")
+	os.WriteFile(bin, nil, 0o755)
+	return keptBuild{Artifact: api.Artifact{ID: id}, binary: bin}
+}
+
+func TestFindArtifact(t *testing.T) {
+	tests := []struct {
+		_name  string
+		active string
+		id     string
+		ok     bool
+		out    string
+	}{
+		{_name: "previous of newest", active: "c", out: "b", ok: true},
+		{_name: "previous of rolled back", active: "b", out: "a", ok: true},
+		{_name: "nothing before oldest", active: "a"},
+		{_name: "by id", active: "c", id: "a", out: "a", ok: true},
+		{_name: "unknown id", active: "c", id: "z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+
+			r := &appRunner{
+				ports: port.New(5000, 5001, port.Config{}),
+				procs: map[int]*process{5000: {build: tt.active}},
+				space: "buffalo",
+				store: artifact.Store{Root: t.TempDir()},
+			}
+			for _, id := range []string{"a", "b", "c"} {
+				r.artifacts = append(r.artifacts, testArtifact(r.store, id))
+			}
+
+			out, ok := r.findArtifactLocked(tt.id)
+			a.Equal(tt.ok, ok)
+			a.Equal(tt.out, out.ID)
+		})
+	}
+}
+
+func TestKeepArtifacts(t *testing.T) {
+	a := assert.New(t)
+
+	r := &appRunner{
+		procs:   map[int]*process{5000: {build: "0"}},
+		space:   "buffalo",
+		store:   artifact.Store{Root: t.TempDir()},
+		workers: map[string]*process{"jobs": {build: "1"}},
+	}
+	var kept []keptBuild
+	for _, id := range []string{"0", "1", "2", "3", "4", "5", "6"} {
+		art := testArtifact(r.store, id)
+		kept = append(kept, art)
+		r.current = art
+		r.keepLocked(art)
+	}
+
+	var ids []string
+	for _, art := range r.artifacts {
+		ids = append(ids, art.ID)
+	}
+	a.Equal([]string{"0", "1", "4", "5", "6"}, ids)
+	a.FileExists(kept[0].binary)
+	a.FileExists(kept[1].binary)
+	a.NoFileExists(kept[2].binary)
+}
+
+func TestSwapPromotes(t *testing.T) {
+	tests := []struct {
+		_name   string
+		healthy bool
+		out     bool
+		crashes int
+		current string
+		kept    []string
+	}{
+		{_name: "healthy", healthy: true, out: true, current: "b", kept: []string{"a", "b"}},
+		{_name: "unhealthy", crashes: 3, current: "a", kept: []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			defer srv.Close()
+			ports := port.New(5000, 5001, port.Config{
+				CheckHealth: func(int) (int, error) {
+					if tt.healthy {
+						return http.StatusOK, nil
+					}
+					return http.StatusServiceUnavailable, nil
+				},
+				CheckInterval: time.Millisecond,
+				CheckRetries:  1,
+				ReportHealth:  func(string, int) {},
+			})
+			output := logs.NewCollector(io.Discard, nil)
+			defer output.Close()
+			r := &appRunner{
+				client:   api.NewClient(srv.URL),
+				commands: map[string]string{"jobs": "sleep 30"},
+				output:   output,
+				ports:    ports,
+				procs:    map[int]*process{},
+				resp:     &api.AppOut{},
+				space:    "buffalo",
+				store:    artifact.Store{Root: t.TempDir()},
+				workers:  map[string]*process{},
+			}
+			defer r.stopAll()
+			r.current = testArtifact(r.store, "a")
+			r.artifacts = []keptBuild{r.current}
+			r.crashes = 3
+
+			b := testArtifact(r.store, "b")
+			a.Equal(tt.out, r.swap(func(int) (keptBuild, error) { return b, nil }))
+			ports.Drained(5000)
+
+			a.Equal(tt.current, r.current.ID)
+			a.Equal(tt.crashes, r.crashes)
+			var ids []string
+			for _, art := range r.artifacts {
+				ids = append(ids, art.ID)
+			}
+			a.Equal(tt.kept, ids)
+			if tt.healthy {
+				a.FileExists(b.binary)
+				a.Equal("b", r.workers["jobs"].build)
+			} else {
+				a.NoFileExists(b.binary)
+				a.Empty(r.workers, "workers only follow a healthy swap")
+			}
+		})
+	}
+}
@@ -2,14 +2,19 @@ package cheetah
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -17,10 +22,12 @@ import (
 
 	"github.com/cockroachdb/errors"
 	"github.com/housecat-inc/cheetah/pkg/api"
+	"github.com/housecat-inc/cheetah/pkg/artifact"
 	"github.com/housecat-inc/cheetah/pkg/build"
 	"github.com/housecat-inc/cheetah/pkg/code"
 	"github.com/housecat-inc/cheetah/pkg/config"
 	"github.com/housecat-inc/cheetah/pkg/deps"
+	"github.com/housecat-inc/cheetah/pkg/gotest"
 	"github.com/housecat-inc/cheetah/pkg/logs"
 	"github.com/housecat-inc/cheetah/pkg/pg"
 	"github.com/housecat-inc/cheetah/pkg/port"
@@ -29,7 +36,30 @@ import (
 
 const defaultURL = "http://localhost:50000"
 
+// Run starts the app with optional default config vars. See RunWith for
+// more options.
 func Run(defaults ...map[string]string) {
+	var opts []Option
+	if len(defaults) > 0 {
+		opts = append(opts, WithDefaults(defaults[0]))
+	}
+	RunWith(opts...)
+}
+
+func RunWith(opts ...Option) {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.watch.Match == nil {
+		o.watch.Match = append([]string{}, DefaultMatch...)
+	}
+	for _, d := range o.staticDirs {
+		match := strings.TrimSuffix(filepath.ToSlash(d), "/") + "/**"
+		o.watch.Match = append(o.watch.Match, match)
+		o.pipeline = append(o.pipeline, Rule{Match: []string{match}, Steps: []Step{StepAsset}})
+	}
+
 	url := config.EnvOr("CHEETAH_URL", defaultURL)
 	space, err := code.System()
 	if err != nil {
@@ -37,10 +67,26 @@ func Run(defaults ...map[string]string) {
 		os.Exit(1)
 	}
 
-	var defs map[string]string
-	if len(defaults) > 0 {
-		defs = defaults[0]
+	targets, err := build.CheckTargets(o.targets)
+	if o.targets == nil {
+		targets, err = build.LoadTargets(space.Dir)
+	}
+	if err != nil {
+		slog.Error("invalid build targets", "error", err)
+		os.Exit(1)
+	}
+	if targets == nil {
+		targets = build.DefaultTargets
+	}
+
+	commands, err := loadProcesses(space.Dir)
+	if err != nil {
+		slog.Error("invalid processes", "error", err)
+		os.Exit(1)
 	}
+	maps.Copy(commands, o.processes)
+
+	defs := o.defaults
 
 	cfg := config.Load(config.DefaultEnv(), space.Dir, config.LoadIn{Defaults: defs})
 
@@ -54,10 +100,12 @@ func Run(defaults ...map[string]string) {
 
 	client := api.NewClient(url)
 	resp, err := client.AppPost(api.AppIn{
-		Config: cfg.Providers,
-		Dir:    space.Dir,
-		Space:  space.Name,
-		Watch:  api.Watch{Match: []string{".envrc", "*.go", "*.sql", "*.templ", "go.mod"}},
+		Callback: callbackFromEnv(),
+		Config:   cfg.Providers,
+		Dir:      space.Dir,
+		Probe:    o.probe,
+		Space:    space.Name,
+		Watch:    o.watch,
 	})
 	if err != nil {
 		l.Error("failed to register", "error", err)
@@ -71,26 +119,54 @@ func Run(defaults ...map[string]string) {
 		client.AppPost(api.AppIn{
 			Config: cfg.Providers,
 			Dir:    space.Dir,
+			Probe:  o.probe,
 			Space:  space.Name,
-			Watch:  api.Watch{Match: []string{".envrc", "*.go", "*.sql", "*.templ", "go.mod"}},
+			Watch:  o.watch,
 		})
 	}
 
-	ports := port.New(resp.Ports.Blue, resp.Ports.Green, port.DefaultConfig(client, space.Name))
+	portCfg := port.DefaultConfig(client, space.Name, o.probe)
+	if o.drainGrace > 0 {
+		portCfg.DrainGrace = o.drainGrace
+	}
+	ports := port.New(resp.Ports.Blue, resp.Ports.Green, portCfg)
+
+	output := logs.NewCollector(os.Stdout, func(entries []api.Log) {
+		client.LogPost(space.Name, entries)
+	})
 
 	runner := &appRunner{
-		appEnv:     cfg.Env,
-		appName:    code.AppName(space.Dir, space.Name),
-		cheetahURL: url,
-		client:     client,
-		cmds:       make(map[int]*exec.Cmd),
-		defs:       defs,
-		dir:        space.Dir,
-		logger:     l,
-		ports:      ports,
-		proxyEnv:   resp.Env,
-		resp:       resp,
-		space:      space.Name,
+		appEnv:      cfg.Env,
+		appName:     code.AppName(space.Dir, space.Name),
+		cheetahURL:  url,
+		client:      client,
+		commands:    commands,
+		defs:        defs,
+		dir:         space.Dir,
+		logger:      l,
+		output:      output,
+		pipeline:    append(o.pipeline, DefaultPipeline(space.Dir)...),
+		ports:       ports,
+		probe:       o.probe,
+		procs:       make(map[int]*process),
+		proxyEnv:    resp.Env,
+		rebuilds:    make(chan struct{}, 1),
+		resp:        resp,
+		restart:     o.restart,
+		space:       space.Name,
+		store:       artifact.DefaultStore(),
+		targets:     targets,
+		testArgs:    o.testArgs,
+		testRetries: o.testRetries,
+		tests:       gotest.DefaultHistory(),
+		watch:       o.watch,
+		workers:     make(map[string]*process),
+	}
+
+	if rec, err := runner.store.DeleteSpace(space.Name); err != nil {
+		l.Warn("build cleanup failed", "error", err)
+	} else if len(rec.Paths) > 0 {
+		l.Info("build cleanup", "reclaimed", rec.Bytes)
 	}
 
 	tmplURL, err := pg.Ensure(resp.DatabaseURL)
@@ -108,21 +184,39 @@ func Run(defaults ...map[string]string) {
 		l.Warn("deps sync failed", "error", err)
 	}
 
-	if err := runner.start(resp.Ports.Blue); err != nil {
+	if t, err := newTemplDev(space.Dir, filepath.Join(os.TempDir(), "cheetah-templ-"+space.Name)); err != nil {
+		l.Warn("templ dev mode off", "error", err)
+	} else if t != nil {
+		l.Info("templ dev mode")
+		runner.templ = t
+	}
+
+	w := watch.NewBatch(space.Dir, o.watch.Match, o.watch.Ignore, runner.changed)
+	inputs := map[string]string{}
+	for path := range w.Scan() {
+		inputs[path] = watch.Hash(path)
+	}
+
+	if b, err := runner.start(context.Background(), resp.Ports.Blue, nil); err != nil {
 		l.Error("initial build failed", "error", err)
 		runner.sendLog("error", fmt.Sprintf("initial build failed: %v", err))
 	} else {
+		runner.promote(b)
+		runner.inputs = inputs
 		ports.ReportHealth("unknown")
-		ports.WaitForHealthy(resp.Ports.Blue)
-		ports.ReportHealth("healthy")
+		if ports.WaitForHealthy(resp.Ports.Blue) {
+			ports.ReportHealth("healthy")
+		} else {
+			l.Error("app did not become ready", "port", resp.Ports.Blue)
+			runner.sendLog("error", "app did not become ready; see WithHealthRetries and WithReadyPath", "event", api.EventSwap)
+			ports.ReportHealth("unhealthy")
+		}
 	}
 
-	w := watch.New(space.Dir, []string{".envrc", "*.go", "*.sql", "*.templ", "go.mod"}, nil, func(path string) {
-		runner.rebuild(path)
-	})
 	w.Start()
 
-	go runner.watchEnvEvents()
+	go runner.rebuildLoop()
+	go runner.watchEvents()
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -131,63 +225,283 @@ func Run(defaults ...map[string]string) {
 	l.Info("shutting down")
 	w.Stop()
 	runner.stopAll()
+	if runner.templ != nil {
+		runner.templ.close()
+	}
+	runner.output.Close()
 	client.AppDelete(space.Name)
 }
 
 type appRunner struct {
 	appEnv              map[string]string
 	appName             string
+	artifacts           []keptBuild
+	buildMu             sync.Mutex
+	cancelBuild         context.CancelFunc
+	cancelTests         context.CancelFunc
 	cheetahURL          string
 	client              *api.Client
-	cmds                map[int]*exec.Cmd
+	commands            map[string]string
+	crashes             int
+	current             keptBuild
 	databaseTemplateURL string
 	defs                map[string]string
 	dir                 string
+	inputs              map[string]string
+	jobs                []func()
 	logger              *slog.Logger
 	mu                  sync.Mutex
+	output              *logs.Collector
+	pending             []watch.Change
+	pipeline            []Rule
 	ports               *port.Manager
+	probe               api.Probe
+	procs               map[int]*process
 	proxyEnv            map[string]string
+	rebuilds            chan struct{}
 	resp                *api.AppOut
+	restart             bool
 	space               string
+	store               artifact.Store
+	targets             []build.Target
+	templ               *templDev
+	testArgs            []string
+	testMu              sync.Mutex
+	testRetries         int
+	tests               gotest.History
+	watch               api.Watch
+	workers             map[string]*process
+}
+
+// start builds with gens, nil meaning go generate, and launches the build
+// on port. It only becomes current once promoted.
+func (r *appRunner) start(ctx context.Context, port int, gens []build.Generator) (keptBuild, error) {
+	r.mu.Lock()
+	b, err := r.startLocked(ctx, port, gens)
+	r.mu.Unlock()
+
+	r.reportProcesses()
+	return b, err
+}
+
+func (r *appRunner) startLocked(ctx context.Context, port int, gens []build.Generator) (keptBuild, error) {
+	in := r.buildIn(port)
+	in.Generate = gens
+	out, err := build.Build(ctx, in)
+	if out.Result.Status != "" {
+		r.client.BuildPost(r.space, out.Result)
+	}
+	if err != nil {
+		return keptBuild{}, err
+	}
+
+	b := keptBuild{
+		Artifact: api.Artifact{
+			BuiltAt: out.Result.FinishedAt,
+			Commit:  out.Result.Commit,
+			ID:      out.Result.ID,
+		},
+		binary:  out.Binary,
+		workers: out.Workers,
+	}
+	return b, r.launchLocked(port, b)
+}
+
+// launch restarts the current build on port without rebuilding.
+func (r *appRunner) launch(port int) (keptBuild, error) {
+	r.mu.Lock()
+	if r.current.binary == "" {
+		r.mu.Unlock()
+		return r.start(context.Background(), port, nil)
+	}
+	defer r.mu.Unlock()
+	return r.current, r.launchLocked(port, r.current)
+}
+
+// swap launches a build on the inactive port with start and promotes it
+// once it is healthy and serving. A build that fails its health check is
+// never current or kept.
+func (r *appRunner) swap(start func(port int) (keptBuild, error)) bool {
+	var b keptBuild
+	ok := r.ports.Swap(func(port int) error {
+		var err error
+		b, err = start(port)
+		return err
+	}, r.stopPort)
+	if ok {
+		r.promote(b)
+	} else if b.ID != "" {
+		r.discard(b)
+	}
+	return ok
 }
 
-func (r *appRunner) start(port int) error {
+// discard deletes the binaries of a build that never went live, unless it
+// is a kept build that failed to relaunch.
+func (r *appRunner) discard(b keptBuild) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if b.ID == r.current.ID || slices.ContainsFunc(r.artifacts, func(a keptBuild) bool { return a.ID == b.ID }) {
+		return
+	}
+	for _, p := range r.workers {
+		if p.build == b.ID {
+			return
+		}
+	}
+	r.store.Delete(r.space, b.ID)
+}
+
+// promote makes b the build that restarts and rollbacks start from, keeps
+// it for rollback and moves the workers onto it. Workers only follow a web
+// process that passed its health check. A new build starts with no crashes
+// counted against it.
+func (r *appRunner) promote(b keptBuild) {
+	r.mu.Lock()
+	if b.ID != r.current.ID {
+		r.crashes = 0
+	}
+	r.current = b
+	if !slices.ContainsFunc(r.artifacts, func(a keptBuild) bool { return a.ID == b.ID }) {
+		r.keepLocked(b)
+	}
+	r.mu.Unlock()
+
+	err := r.startWorkers(b)
+
+	if err != nil {
+		r.logger.Error("worker start failed", "error", err)
+		r.sendLog("error", fmt.Sprintf("worker start failed: %v", err))
+	}
+	r.reportArtifacts()
+	r.reportProcesses()
+}
+
+func (r *appRunner) launchLocked(port int, a keptBuild) error {
+	// Blue and green write at once during a swap, so each gets its own line
+	// buffer, tagged with its port.
+	tail := logs.NewTail(crashTailLines)
+	in := r.buildIn(port)
+	in.Output = io.MultiWriter(r.output.Process(strconv.Itoa(port)), tail)
+
+	cmd, err := build.Start(in, a.binary)
+	if err != nil {
+		return err
+	}
+	p := r.supervise(cmd, r.targets[0].Name, port, tail)
+	p.build = a.ID
+	r.procs[port] = p
+	return nil
+}
 
-	out, err := build.Run(build.In{
-		AppEnv:              r.appEnv,
+// buildIn is the build input for port. Callers hold mu, since the env and
+// template change under it.
+func (r *appRunner) buildIn(port int) build.In {
+	env := r.appEnv
+	if r.templ != nil {
+		env = maps.Clone(r.appEnv)
+		if env == nil {
+			env = map[string]string{}
+		}
+		maps.Copy(env, r.templ.env())
+	}
+	return build.In{
+		AppEnv:              env,
 		CheetahURL:          r.cheetahURL,
 		DatabaseTemplateURL: r.databaseTemplateURL,
 		DatabaseURL:         r.resp.DatabaseURL,
+		Output:              r.output,
 		Port:                port,
 		Space:               r.space,
-	})
-	if err != nil {
-		return err
+		Store:               r.store,
+		Targets:             r.targets,
 	}
+}
 
-	r.cmds[port] = out.Cmd
-	return nil
+// changed queues a batch from the watcher for rebuildLoop, canceling a
+// build that is still compiling older changes.
+func (r *appRunner) changed(changes []watch.Change) {
+	r.buildMu.Lock()
+	r.pending = append(r.pending, changes...)
+	if r.cancelBuild != nil {
+		r.cancelBuild()
+	}
+	r.buildMu.Unlock()
+
+	select {
+	case r.rebuilds <- struct{}{}:
+	default:
+	}
+}
+
+// queue runs job on rebuildLoop, so it never builds or swaps at the same
+// time as a rebuild.
+func (r *appRunner) queue(job func()) {
+	r.buildMu.Lock()
+	r.jobs = append(r.jobs, job)
+	r.buildMu.Unlock()
+
+	select {
+	case r.rebuilds <- struct{}{}:
+	default:
+	}
+}
+
+// rebuildLoop runs the queued jobs, then one rebuild for everything that
+// changed since the last one started. Every build and swap happens here.
+func (r *appRunner) rebuildLoop() {
+	for range r.rebuilds {
+		r.buildMu.Lock()
+		changes, jobs := r.pending, r.jobs
+		r.pending, r.jobs = nil, nil
+		r.buildMu.Unlock()
+
+		for _, job := range jobs {
+			job()
+		}
+		if len(changes) > 0 {
+			r.rebuild(changes)
+		}
+	}
 }
 
-func (r *appRunner) rebuild(changedPath string) {
-	if rel, err := filepath.Rel(r.dir, changedPath); err == nil {
-		changedPath = rel
+func (r *appRunner) rebuild(changes []watch.Change) {
+	changes, hashes := r.diffInputs(changes)
+	if changes != nil && len(changes) == 0 {
+		// Nothing is posted so a failed build stays the latest one.
+		r.logger.Info("builder", "skipped", "no changes")
+		return
+	}
+
+	for _, c := range changes {
+		r.logger.Debug("change", "op", c.Op, "path", c.Path)
+	}
+	textOnly := false
+	if r.templ != nil {
+		changes, textOnly = r.templ.apply(changes)
+		if textOnly && len(changes) == 0 {
+			r.logger.Info("builder", "templ", "text only")
+			r.client.Reload(r.space)
+			r.recordInputs(hashes)
+			return
+		}
 	}
-	r.logger.Info("builder")
+	p := newPlan(r.pipeline, r.dir, changes)
+	r.logger.Info("builder", "changes", len(changes), "build", p.has(StepBuild))
 
-	if filepath.Base(changedPath) == ".envrc" {
+	if p.has(StepConfig) {
 		if err := config.Sync(config.DefaultConfig(), r.dir); err != nil {
 			r.logger.Error("config sync failed", "error", err)
 			r.sendLog("error", fmt.Sprintf("config sync failed: %v", err))
 			return
 		}
 		cfg := config.Load(config.DefaultEnv(), r.dir, config.LoadIn{Defaults: r.defs, ProxyEnv: r.proxyEnv})
+		r.mu.Lock()
 		r.appEnv = cfg.Env
+		r.mu.Unlock()
 	}
 
-	if filepath.Base(changedPath) == "go.mod" {
+	if p.has(StepDeps) {
 		if err := deps.Sync(deps.DefaultConfig()); err != nil {
 			r.logger.Error("deps sync failed", "error", err)
 			r.sendLog("error", fmt.Sprintf("deps sync failed: %v", err))
@@ -195,77 +509,166 @@ func (r *appRunner) rebuild(changedPath string) {
 		}
 	}
 
-	if strings.HasSuffix(changedPath, ".sql") {
-		r.logger.Info("migrator", "path", changedPath)
+	if p.has(StepMigrate) {
+		r.logger.Info("migrator")
 		tmplURL, err := pg.Ensure(r.resp.DatabaseURL)
 		if err != nil {
 			r.logger.Error("database rebuild failed", "error", err)
 			r.sendLog("error", fmt.Sprintf("database rebuild failed: %v", err))
 			return
 		}
+		r.mu.Lock()
 		r.databaseTemplateURL = tmplURL
+		r.mu.Unlock()
 	}
 
-	if !r.ports.Swap(r.start, r.stopPort) {
-		r.logger.Error("swap failed")
-		r.sendLog("error", "swap failed")
+	if !p.has(StepBuild) && p.has(StepRestart) {
+		if !r.swap(r.launch) {
+			r.logger.Error("swap failed")
+			r.sendLog("error", "swap failed", "event", api.EventSwap)
+			return
+		}
+		r.recordInputs(hashes)
+		return
+	}
+	if !p.has(StepBuild) {
+		if p.has(StepReload) || textOnly {
+			r.client.Reload(r.space)
+		} else if p.has(StepAsset) {
+			r.client.Assets(r.space, p.assets)
+		}
+		r.recordInputs(hashes)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.buildMu.Lock()
+	r.cancelBuild = cancel
+	r.buildMu.Unlock()
+	defer func() {
+		r.buildMu.Lock()
+		r.cancelBuild = nil
+		r.buildMu.Unlock()
+		cancel()
+	}()
+
+	gens := p.generators()
+	start := func(port int) (keptBuild, error) { return r.start(ctx, port, gens) }
+	if r.swap(start) {
+		r.recordInputs(hashes)
+		if r.testArgs != nil && changes != nil {
+			go r.testAffected(changes)
+		}
+		return
+	}
+	if ctx.Err() != nil {
+		r.logger.Info("build canceled for newer changes")
+		r.buildMu.Lock()
+		r.pending = append(changes, r.pending...)
+		r.buildMu.Unlock()
+		return
+	}
+	r.logger.Error("swap failed")
+	r.sendLog("error", "swap failed", "event", api.EventSwap)
+}
+
+// diffInputs drops changes that leave a file with the content the running
+// build was made from, e.g. a touch or a branch switch there and back. It
+// returns the remaining changes and their new hashes. Nil changes, as from
+// the dashboard's rebuild button, pass through, and so does everything when
+// the running build's inputs are unknown, as after a rollback.
+func (r *appRunner) diffInputs(changes []watch.Change) ([]watch.Change, map[string]string) {
+	if changes == nil {
+		return nil, nil
+	}
+	r.buildMu.Lock()
+	defer r.buildMu.Unlock()
+
+	dirty := []watch.Change{}
+	hashes := map[string]string{}
+	for _, c := range changes {
+		h := ""
+		if c.Op != watch.OpDeleted {
+			h = watch.Hash(c.Path)
+		}
+		prev, ok := r.inputs[c.Path]
+		if r.inputs != nil && (ok && prev == h || !ok && h == "") {
+			continue
+		}
+		dirty = append(dirty, c)
+		hashes[c.Path] = h
+	}
+	return dirty, hashes
+}
+
+// recordInputs notes the hashes of a build that went live.
+func (r *appRunner) recordInputs(hashes map[string]string) {
+	r.buildMu.Lock()
+	defer r.buildMu.Unlock()
+
+	if r.inputs == nil {
+		r.inputs = map[string]string{}
+	}
+	for path, h := range hashes {
+		if h == "" {
+			delete(r.inputs, path)
+		} else {
+			r.inputs[path] = h
+		}
 	}
 }
 
 func (r *appRunner) stopPort(port int) {
 	r.mu.Lock()
-	cmd := r.cmds[port]
-	delete(r.cmds, port)
+	p := r.procs[port]
+	delete(r.procs, port)
 	r.mu.Unlock()
 
-	stopProcess(cmd)
+	p.stop()
+	r.reportArtifacts()
+	r.reportProcesses()
 }
 
 func (r *appRunner) stopAll() {
 	r.mu.Lock()
-	cmds := r.cmds
-	r.cmds = make(map[int]*exec.Cmd)
+	procs := r.procs
+	workers := r.workers
+	r.procs = make(map[int]*process)
+	r.workers = make(map[string]*process)
 	r.mu.Unlock()
 
-	for _, cmd := range cmds {
-		stopProcess(cmd)
-	}
-}
-
-func stopProcess(cmd *exec.Cmd) {
-	if cmd == nil || cmd.Process == nil {
-		return
+	for _, p := range procs {
+		p.stop()
 	}
-
-	cmd.Process.Signal(syscall.SIGTERM)
-
-	done := make(chan error, 1)
-	go func() { done <- cmd.Wait() }()
-
-	select {
-	case <-done:
-	case <-time.After(5 * time.Second):
-		cmd.Process.Kill()
-		<-done
+	for _, p := range workers {
+		p.stop()
 	}
 }
 
-func (r *appRunner) sendLog(level, message string) {
+func (r *appRunner) sendLog(level, message string, args ...string) {
+	var attrs map[string]any
+	for i := 0; i+1 < len(args); i += 2 {
+		if attrs == nil {
+			attrs = map[string]any{}
+		}
+		attrs[args[i]] = args[i+1]
+	}
 	r.client.LogPost(r.space, []api.Log{{
+		Attrs:     attrs,
 		Level:     level,
 		Message:   message,
 		Timestamp: time.Now(),
 	}})
 }
 
-func (r *appRunner) watchEnvEvents() {
+func (r *appRunner) watchEvents() {
 	for {
-		r.listenEnvEvents()
+		r.listenEvents()
 		time.Sleep(2 * time.Second)
 	}
 }
 
-func (r *appRunner) listenEnvEvents() {
+func (r *appRunner) listenEvents() {
 	resp, err := http.Get(r.cheetahURL + "/api/events")
 	if err != nil {
 		return
@@ -285,7 +688,13 @@ func (r *appRunner) listenEnvEvents() {
 				Vars map[string]string `json:"vars"`
 			}
 			if json.Unmarshal([]byte(data), &payload) == nil && payload.App == r.appName {
-				r.envReload(payload.Vars)
+				r.queue(func() { r.envReload(payload.Vars) })
+			}
+			eventType = ""
+		} else if strings.HasPrefix(line, "data: ") && eventType == "action" {
+			var action api.Action
+			if json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &action) == nil && action.Space == r.space {
+				r.queue(func() { r.action(action) })
 			}
 			eventType = ""
 		} else if line == "" {
@@ -294,23 +703,75 @@ func (r *appRunner) listenEnvEvents() {
 	}
 }
 
+// action runs a dashboard or MCP action. It is queued on rebuildLoop, like
+// envReload, so it never swaps at the same time as a rebuild.
+func (r *appRunner) action(a api.Action) {
+	action := a.Action
+	r.logger.Info("action", "action", action, "build", a.BuildID)
+
+	switch action {
+	case api.ActionRebuild:
+		r.rebuild(nil)
+		return
+	case api.ActionReset:
+		tmplURL, err := pg.Ensure(r.resp.DatabaseURL)
+		if err != nil {
+			r.logger.Error("database reset failed", "error", err)
+			r.sendLog("error", fmt.Sprintf("database reset failed: %v", err))
+			return
+		}
+		r.mu.Lock()
+		r.databaseTemplateURL = tmplURL
+		r.mu.Unlock()
+	case api.ActionRestart:
+	case api.ActionRollback:
+		r.rollback(a.BuildID)
+		return
+	case api.ActionTest:
+		go r.runTests(context.Background(), a.TestID, a.Args)
+		return
+	default:
+		return
+	}
+
+	if !r.swap(r.launch) {
+		r.logger.Error("swap failed")
+		r.sendLog("error", fmt.Sprintf("swap failed after %s", action), "event", api.EventSwap)
+	}
+}
+
 func (r *appRunner) envReload(vars map[string]string) {
 	r.logger.Info("env update from dashboard")
 	r.proxyEnv = vars
 	cfg := config.Load(config.DefaultEnv(), r.dir, config.LoadIn{Defaults: r.defs, ProxyEnv: r.proxyEnv})
+	r.mu.Lock()
 	r.appEnv = cfg.Env
+	r.mu.Unlock()
 
 	r.client.AppPost(api.AppIn{
 		Config: cfg.Providers,
 		Dir:    r.dir,
+		Probe:  r.probe,
 		Space:  r.space,
-		Watch:  api.Watch{Match: []string{".envrc", "*.go", "*.sql", "*.templ", "go.mod"}},
+		Watch:  r.watch,
 	})
 
-	if !r.ports.Swap(r.start, r.stopPort) {
+	start := func(port int) (keptBuild, error) { return r.start(context.Background(), port, nil) }
+	if !r.swap(start) {
 		r.logger.Error("swap failed")
-		r.sendLog("error", "swap failed after env update")
+		r.sendLog("error", "swap failed after env update", "event", api.EventSwap)
+	}
+}
+
+func callbackFromEnv() *api.Callback {
+	cb := api.Callback{
+		Command: os.Getenv("CHEETAH_CALLBACK_COMMAND"),
+		URL:     os.Getenv("CHEETAH_CALLBACK_URL"),
+	}
+	if cb.Command == "" && cb.URL == "" {
+		return nil
 	}
+	return &cb
 }
 
 func ensureInfra(url string) error {
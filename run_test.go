@@ -0,0 +1,139 @@
+package cheetah
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/housecat-inc/cheetah/pkg/watch"
+)
+
+func TestChangedCancelsBuild(t *testing.T) {
+	a := assert.New(t)
+
+	r := &appRunner{rebuilds: make(chan struct{}, 1)}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancelBuild = cancel
+
+	r.changed([]watch.Change{{Op: watch.OpModified, Path: "a.go"}})
+	r.changed([]watch.Change{{Op: watch.OpAdded, Path: "b.go"}})
+
+	a.Error(ctx.Err())
+	a.Len(r.rebuilds, 1)
+	a.Equal([]watch.Change{
+		{Op: watch.OpModified, Path: "a.go"},
+		{Op: watch.OpAdded, Path: "b.go"},
+	}, r.pending)
+}
+
+func TestQueue(t *testing.T) {
+	a := assert.New(t)
+
+	r := &appRunner{rebuilds: make(chan struct{}, 1)}
+	done := make(chan struct{})
+	go func() {
+		r.rebuildLoop()
+		close(done)
+	}()
+
+	var ran []int
+	var wg sync.WaitGroup
+	wg.Add(3)
+	for i := range 3 {
+		r.queue(func() {
+			ran = append(ran, i)
+			wg.Done()
+		})
+	}
+	wg.Wait()
+	close(r.rebuilds)
+	<-done
+	a.Equal([]int{0, 1, 2}, ran)
+}
+
+func TestDiffInputs(t *testing.T) {
+	dir := t.TempDir()
+	same := filepath.Join(dir, "same.go")
+	edited := filepath.Join(dir, "edited.go")
+	added := filepath.Join(dir, "added.go")
+	gone := filepath.Join(dir, "gone.go")
+	os.WriteFile(same, []byte("package a"), 0o644)
+	os.WriteFile(edited, []byte("package a // v2"), 0o644)
+	os.WriteFile(added, []byte("package a"), 0o644)
+
+	inputs := map[string]string{
+		edited: watch.Hash(same),
+		gone:   watch.Hash(same),
+		same:   watch.Hash(same),
+	}
+
+	tests := []struct {
+		_name   string
+		changes []watch.Change
+		unknown bool
+		out     []watch.Change
+	}{
+		{
+			_name:   "manual rebuild",
+			changes: nil,
+			out:     nil,
+		},
+		{
+			_name:   "touched",
+			changes: []watch.Change{{Op: watch.OpModified, Path: same}},
+			out:     []watch.Change{},
+		},
+		{
+			_name: "edited and added",
+			changes: []watch.Change{
+				{Op: watch.OpModified, Path: same},
+				{Op: watch.OpModified, Path: edited},
+				{Op: watch.OpAdded, Path: added},
+			},
+			out: []watch.Change{
+				{Op: watch.OpModified, Path: edited},
+				{Op: watch.OpAdded, Path: added},
+			},
+		},
+		{
+			_name:   "deleted",
+			changes: []watch.Change{{Op: watch.OpDeleted, Path: gone}},
+			out:     []watch.Change{{Op: watch.OpDeleted, Path: gone}},
+		},
+		{
+			_name:   "deleted unknown",
+			changes: []watch.Change{{Op: watch.OpDeleted, Path: filepath.Join(dir, "tmp.go")}},
+			out:     []watch.Change{},
+		},
+		{
+			_name:   "touched after rollback",
+			changes: []watch.Change{{Op: watch.OpModified, Path: same}, {Op: watch.OpDeleted, Path: gone}},
+			unknown: true,
+			out:     []watch.Change{{Op: watch.OpModified, Path: same}, {Op: watch.OpDeleted, Path: gone}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+			r := &appRunner{inputs: inputs}
+			if tt.unknown {
+				r.inputs = nil
+			}
+			out, _ := r.diffInputs(tt.changes)
+			a.Equal(tt.out, out)
+		})
+	}
+}
+
+func TestRecordInputs(t *testing.T) {
+	a := assert.New(t)
+
+	r := &appRunner{inputs: map[string]string{"a.go": "1", "b.go": "2"}}
+	r.recordInputs(map[string]string{"a.go": "3", "b.go": "", "c.go": "4"})
+	a.Equal(map[string]string{"a.go": "3", "c.go": "4"}, r.inputs)
+}
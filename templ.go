@@ -0,0 +1,156 @@
+package cheetah
+
+import (
+	"bufio"
+	"bytes"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/a-h/templ"
+	"github.com/a-h/templ/generator"
+	"github.com/a-h/templ/parser/v2"
+	templruntime "github.com/a-h/templ/runtime"
+	"github.com/cockroachdb/errors"
+
+	"github.com/housecat-inc/cheetah/pkg/watch"
+)
+
+// templDev is templ's dev mode. Apps run with TEMPL_DEV_MODE read template
+// text from files under root at render time, so an edit that only changes
+// text needs a browser reload instead of a rebuild.
+type templDev struct {
+	dir     string
+	mu      sync.Mutex
+	outputs map[string]generator.GeneratorOutput
+	root    string
+}
+
+// newTemplDev enables dev mode for the app in dir. The text files have to
+// line up with the generated code, so it returns nil unless every .templ
+// file was generated by the templ version cheetah embeds and parses.
+func newTemplDev(dir, root string) (*templDev, error) {
+	var files []string
+	for path := range watch.New(dir, []string{"*.templ"}, nil, nil).Scan() {
+		files = append(files, path)
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	for _, f := range files {
+		gen := strings.TrimSuffix(f, ".templ") + "_templ.go"
+		if v := templVersion(gen); v != templ.Version() {
+			return nil, errors.Newf("%s generated by templ %q, not %s", filepath.Base(gen), v, templ.Version())
+		}
+	}
+
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, errors.Wrap(err, "templ dev dir")
+	}
+	t := &templDev{
+		dir:     dir,
+		outputs: map[string]generator.GeneratorOutput{},
+		root:    root,
+	}
+	for _, f := range files {
+		if _, err := t.edit(f); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// env is what the app needs to read text from root.
+func (t *templDev) env() map[string]string {
+	return map[string]string{
+		"TEMPL_DEV_MODE":      "true",
+		"TEMPL_DEV_MODE_ROOT": t.root,
+	}
+}
+
+// apply writes text for changed .templ files and drops those whose Go code
+// is unchanged, reporting whether any were dropped. Anything that fails to
+// generate stays for the build to report.
+func (t *templDev) apply(changes []watch.Change) ([]watch.Change, bool) {
+	if changes == nil {
+		return nil, false
+	}
+	kept := []watch.Change{}
+	textOnly := false
+	for _, c := range changes {
+		if filepath.Ext(c.Path) != ".templ" || c.Op == watch.OpDeleted {
+			kept = append(kept, c)
+			continue
+		}
+		goChanged, err := t.edit(c.Path)
+		if err != nil || goChanged {
+			kept = append(kept, c)
+			continue
+		}
+		textOnly = true
+	}
+	return kept, textOnly
+}
+
+// edit generates file, writes its Go code and its text for the app, and
+// reports whether its Go code changed since the last edit. The Go file is
+// kept current so builds without dev mode serve the same markup.
+func (t *templDev) edit(file string) (bool, error) {
+	tf, err := parser.Parse(file)
+	if err != nil {
+		return true, errors.Wrapf(err, "parse %s", file)
+	}
+	rel, err := filepath.Rel(t.dir, file)
+	if err != nil {
+		rel = file
+	}
+	var code bytes.Buffer
+	out, err := generator.Generate(tf, &code, generator.WithVersion(templ.Version()), generator.WithFileName(filepath.ToSlash(rel)))
+	if err != nil {
+		return true, errors.Wrapf(err, "generate %s", file)
+	}
+	formatted, err := format.Source(code.Bytes())
+	if err != nil {
+		return true, errors.Wrapf(err, "format %s", file)
+	}
+	gen := strings.TrimSuffix(file, ".templ") + "_templ.go"
+	if prev, err := os.ReadFile(gen); err != nil || !bytes.Equal(prev, formatted) {
+		if err := os.WriteFile(gen, formatted, 0o644); err != nil {
+			return true, errors.Wrap(err, "write templ go")
+		}
+	}
+
+	txt := filepath.Join(t.root, filepath.Base(templruntime.GetDevModeTextFileName(file)))
+	if err := os.WriteFile(txt, []byte(strings.Join(out.Literals, "\n")), 0o644); err != nil {
+		return true, errors.Wrap(err, "write templ text")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	prev, ok := t.outputs[file]
+	t.outputs[file] = out
+	return !ok || generator.HasGoChanged(prev, out), nil
+}
+
+func (t *templDev) close() {
+	os.RemoveAll(t.root)
+}
+
+// templVersion reads the "// templ: version:" header of a generated file.
+func templVersion(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < 5 && scanner.Scan(); i++ {
+		if v, ok := strings.CutPrefix(scanner.Text(), "// templ: version: "); ok {
+			return v
+		}
+	}
+	return ""
+}
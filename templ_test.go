@@ -0,0 +1,98 @@
+package cheetah
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/a-h/templ"
+	templruntime "github.com/a-h/templ/runtime"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/housecat-inc/cheetah/pkg/watch"
+)
+
+const homeTempl = `package views
+
+templ Home(name string) {
+	<h1>Hello, { name }</h1>
+}
+`
+
+func writeTemplApp(t *testing.T, version string) (string, string) {
+	dir := t.TempDir()
+	home := filepath.Join(dir, "home.templ")
+	os.WriteFile(home, []byte(homeTempl), 0o644)
+	os.WriteFile(filepath.Join(dir, "home_templ.go"), []byte("// Code generated by templ - DO NOT EDIT.\n\n// templ: version: "+version+"\npackage views\n"), 0o644)
+	return dir, home
+}
+
+func TestNewTemplDev(t *testing.T) {
+	tests := []struct {
+		_name   string
+		version string
+		out     bool
+	}{
+		{_name: "same version", version: templ.Version(), out: true},
+		{_name: "other version", version: "v0.2.543", out: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt._name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir, _ := writeTemplApp(t, tt.version)
+			td, err := newTemplDev(dir, t.TempDir())
+			a.Equal(tt.out, td != nil)
+			a.Equal(tt.out, err == nil)
+		})
+	}
+
+	td, err := newTemplDev(t.TempDir(), t.TempDir())
+	assert.Nil(t, td)
+	assert.NoError(t, err)
+}
+
+func TestTemplDevApply(t *testing.T) {
+	a := assert.New(t)
+
+	dir, home := writeTemplApp(t, templ.Version())
+	root := t.TempDir()
+	td, err := newTemplDev(dir, root)
+	a.NoError(err)
+
+	txt := filepath.Join(root, filepath.Base(templruntime.GetDevModeTextFileName(home)))
+	b, _ := os.ReadFile(txt)
+	a.Contains(string(b), "Hello, ")
+
+	main := watch.Change{Op: watch.OpModified, Path: filepath.Join(dir, "main.go")}
+	change := watch.Change{Op: watch.OpModified, Path: home}
+
+	os.WriteFile(home, []byte(`package views
+
+templ Home(name string) {
+	<h1>Welcome, { name }</h1>
+}
+`), 0o644)
+	changes, textOnly := td.apply([]watch.Change{change, main})
+	a.True(textOnly)
+	a.Equal([]watch.Change{main}, changes)
+	b, _ = os.ReadFile(txt)
+	a.Contains(string(b), "Welcome, ")
+	b, _ = os.ReadFile(filepath.Join(dir, "home_templ.go"))
+	a.Contains(string(b), "Welcome, ")
+
+	os.WriteFile(home, []byte(`package views
+
+templ Home(name string, n int) {
+	<h1>Welcome, { name } #{ n }</h1>
+}
+`), 0o644)
+	changes, textOnly = td.apply([]watch.Change{change})
+	a.False(textOnly)
+	a.Equal([]watch.Change{change}, changes)
+
+	changes, textOnly = td.apply(nil)
+	a.Nil(changes)
+	a.False(textOnly)
+}
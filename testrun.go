@@ -0,0 +1,111 @@
+package cheetah
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/housecat-inc/cheetah/pkg/api"
+	"github.com/housecat-inc/cheetah/pkg/build"
+	"github.com/housecat-inc/cheetah/pkg/gotest"
+	"github.com/housecat-inc/cheetah/pkg/pg"
+	"github.com/housecat-inc/cheetah/pkg/watch"
+)
+
+// runTests runs go test for cheetah test, the dashboard or a rebuild with
+// the space's env and a fresh copy of the template database, one run at a
+// time. Results are sent as each package finishes. A run canceled before
+// its turn is dropped; one canceled while running ends "canceled".
+func (r *appRunner) runTests(ctx context.Context, id string, args []string) {
+	r.testMu.Lock()
+	defer r.testMu.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+
+	run := api.TestRun{Args: args, ID: id, StartedAt: time.Now(), Status: "running", Tests: []api.TestResult{}}
+	r.client.TestPut(r.space, run)
+	r.logger.Info("tests", "args", args)
+
+	r.mu.Lock()
+	in := r.buildIn(0)
+	r.mu.Unlock()
+	dbURL, cleanup, err := pg.CreateTestDB(in.DatabaseTemplateURL)
+	if err != nil {
+		run.FinishedAt = time.Now()
+		run.Output = fmt.Sprintf("create test database: %v", err)
+		run.Status = "error"
+		r.client.TestPut(r.space, run)
+		return
+	}
+	defer cleanup()
+	in.DatabaseURL = dbURL
+
+	run = gotest.Run(ctx, gotest.In{
+		Args: args,
+		Dir:  r.dir,
+		Env:  build.Env(in),
+		Progress: func(partial api.TestRun) {
+			partial.ID = id
+			r.client.TestPut(r.space, partial)
+		},
+		Retries: r.testRetries,
+	})
+	run.ID = id
+	if ctx.Err() != nil {
+		run.Status = "canceled"
+	} else {
+		r.recordTests(&run)
+	}
+	r.logger.Info("tests", "status", run.Status, "passed", run.Passed, "failed", run.Failed, "skipped", run.Skipped, "flaky", run.Flaky)
+	r.client.TestPut(r.space, run)
+}
+
+// recordTests adds run to the test history and flags the tests that have
+// passed and failed at this commit before.
+func (r *appRunner) recordTests(run *api.TestRun) {
+	commit := gotest.Commit(r.dir)
+	if err := r.tests.Record(commit, *run); err != nil {
+		r.logger.Warn("test history failed", "error", err)
+		return
+	}
+	if commit == "" || run.Failed == 0 {
+		return
+	}
+	outcomes, err := r.tests.Load()
+	if err != nil {
+		r.logger.Warn("test history failed", "error", err)
+		return
+	}
+	gotest.MarkFlaky(run, commit, outcomes)
+}
+
+// testAffected runs the tests of the packages changes affect after they
+// went live, canceling a run still going for earlier changes.
+func (r *appRunner) testAffected(changes []watch.Change) {
+	paths := make([]string, len(changes))
+	for i, c := range changes {
+		paths[i] = c.Path
+	}
+	pkgs, err := gotest.Affected(r.dir, paths)
+	if err != nil {
+		r.logger.Error("affected packages failed", "error", err)
+		return
+	}
+	if len(pkgs) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.buildMu.Lock()
+	if r.cancelTests != nil {
+		r.cancelTests()
+	}
+	r.cancelTests = cancel
+	r.buildMu.Unlock()
+
+	id := api.NewID(time.Now())
+	r.runTests(ctx, id, append(slices.Clone(r.testArgs), pkgs...))
+}